@@ -27,6 +27,9 @@ type Cache interface {
 
 	// Add an item to the cache, replacing any existing item, using the default expiration
 	Set(key string, value interface{})
+
+	// Delete every item from the cache
+	Flush()
 }
 
 type noOpCache struct {
@@ -39,6 +42,9 @@ func (c *noOpCache) Get(key string) (interface{}, bool) {
 func (c *noOpCache) Set(key string, value interface{}) {
 }
 
+func (c *noOpCache) Flush() {
+}
+
 type inMemoryCache struct {
 	cache *cache.Cache
 }
@@ -51,6 +57,10 @@ func (c *inMemoryCache) Set(key string, value interface{}) {
 	c.cache.SetDefault(key, value)
 }
 
+func (c *inMemoryCache) Flush() {
+	c.cache.Flush()
+}
+
 // NewCache creates a new caching.Cache
 func NewCache(config config.CacheConfig) *Cache {
 	var result Cache
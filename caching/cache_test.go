@@ -47,3 +47,12 @@ func TestNewCache(t *testing.T) {
 		}
 	}
 }
+
+func TestFlush(t *testing.T) {
+	cache := *NewCache(config.CacheConfig{ConsulCacheDuration: time.Minute})
+	cache.Set("foo", "bar")
+	cache.Flush()
+	if _, ok := cache.Get("foo"); ok {
+		t.Error("Result: want record flushed, got record")
+	}
+}
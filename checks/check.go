@@ -15,6 +15,7 @@
 package checks
 
 import (
+	"encoding/json"
 	"github.com/pkg/errors"
 	"time"
 )
@@ -101,10 +102,11 @@ type Check struct {
 	Output      string `json:",omitempty"`
 	ServiceID   string
 	ServiceName string
-	ServiceTags []string        `json:",omitempty"`
-	Definition  CheckDefinition `json:"-"`
-	CreateIndex uint64          `json:",omitempty"`
-	ModifyIndex uint64          `json:",omitempty"`
+	ServiceTags []string         `json:",omitempty"`
+	Type        string           `json:",omitempty"`
+	Definition  *CheckDefinition `json:",omitempty"`
+	CreateIndex uint64           `json:",omitempty"`
+	ModifyIndex uint64           `json:",omitempty"`
 }
 
 // MatchStatus returns a Status that indicates how the Status of a Check matches the specified status.
@@ -142,6 +144,14 @@ func (c *Check) IsCheckName(checkName string) bool {
 	return checkName == c.Name
 }
 
+// WithoutDefinition returns a copy of the Check without its Type and Definition.
+func (c *Check) WithoutDefinition() *Check {
+	copied := *c
+	copied.Type = ""
+	copied.Definition = nil
+	return &copied
+}
+
 // CheckDefinition represents the configuration of a Consul check.
 type CheckDefinition struct {
 	HTTP                           string
@@ -153,3 +163,111 @@ type CheckDefinition struct {
 	Timeout                        time.Duration
 	DeregisterCriticalServiceAfter time.Duration
 }
+
+// Target returns what the check probes, which is the HTTP URL or the TCP address.
+func (d *CheckDefinition) Target() string {
+	if d.HTTP != "" {
+		return d.HTTP
+	}
+	return d.TCP
+}
+
+// redactedHeaderValue replaces the header values of check definitions in responses.
+const redactedHeaderValue = "xxxxx"
+
+type checkDefinitionJSON struct {
+	Target                         string              `json:",omitempty"`
+	HTTP                           string              `json:",omitempty"`
+	Header                         map[string][]string `json:",omitempty"`
+	Method                         string              `json:",omitempty"`
+	TLSSkipVerify                  bool                `json:",omitempty"`
+	TCP                            string              `json:",omitempty"`
+	Interval                       string              `json:",omitempty"`
+	Timeout                        string              `json:",omitempty"`
+	DeregisterCriticalServiceAfter string              `json:",omitempty"`
+}
+
+// MarshalJSON encodes the CheckDefinition with its Target, omitting empty fields and
+// encoding durations as strings, like 10s.  Header values are redacted, since they can hold
+// credentials, like Authorization.
+func (d CheckDefinition) MarshalJSON() ([]byte, error) {
+	var header map[string][]string
+	if len(d.Header) > 0 {
+		header = make(map[string][]string, len(d.Header))
+		for k, values := range d.Header {
+			redacted := make([]string, len(values))
+			for i := range values {
+				redacted[i] = redactedHeaderValue
+			}
+			header[k] = redacted
+		}
+	}
+	return json.Marshal(checkDefinitionJSON{
+		Target:                         d.Target(),
+		HTTP:                           d.HTTP,
+		Header:                         header,
+		Method:                         d.Method,
+		TLSSkipVerify:                  d.TLSSkipVerify,
+		TCP:                            d.TCP,
+		Interval:                       formatDuration(d.Interval),
+		Timeout:                        formatDuration(d.Timeout),
+		DeregisterCriticalServiceAfter: formatDuration(d.DeregisterCriticalServiceAfter),
+	})
+}
+
+// UnmarshalJSON decodes a CheckDefinition from Consul, whose durations are either strings,
+// like 10s, or nanoseconds.
+func (d *CheckDefinition) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		HTTP                           string
+		Header                         map[string][]string
+		Method                         string
+		TLSSkipVerify                  bool
+		TCP                            string
+		Interval                       json.RawMessage
+		Timeout                        json.RawMessage
+		DeregisterCriticalServiceAfter json.RawMessage
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	d.HTTP, d.Header, d.Method, d.TLSSkipVerify, d.TCP = raw.HTTP, raw.Header, raw.Method, raw.TLSSkipVerify, raw.TCP
+	var err error
+	if d.Interval, err = parseDuration(raw.Interval); err != nil {
+		return err
+	}
+	if d.Timeout, err = parseDuration(raw.Timeout); err != nil {
+		return err
+	}
+	d.DeregisterCriticalServiceAfter, err = parseDuration(raw.DeregisterCriticalServiceAfter)
+	return err
+}
+
+func parseDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, errors.Wrapf(err, "Unsupported duration: %s", s)
+		}
+		return d, nil
+	}
+	var ns int64
+	if err := json.Unmarshal(data, &ns); err != nil {
+		return 0, errors.Errorf("Unsupported duration: %s", string(data))
+	}
+	return time.Duration(ns), nil
+}
+
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return d.String()
+}
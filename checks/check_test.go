@@ -14,7 +14,11 @@
 
 package checks
 
-import "testing"
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
 
 var matchesStatusData = []struct {
 	threshold HealthStatus
@@ -124,3 +128,65 @@ func TestIsServiceName(t *testing.T) {
 		t.Error("ServiceName 'a' => 'b', want 'false', got 'true'")
 	}
 }
+
+func TestCheckDefinitionJSON(t *testing.T) {
+	for _, d := range []struct {
+		consul   string
+		expected string
+	}{
+		{`{"HTTP":"http://localhost/health","Method":"GET","Interval":"10s","Timeout":"1s","DeregisterCriticalServiceAfter":"0s"}`,
+			`{"Target":"http://localhost/health","HTTP":"http://localhost/health","Method":"GET","Interval":"10s","Timeout":"1s"}`},
+		{`{"TCP":"localhost:22","Interval":10000000000,"Timeout":1000000000}`,
+			`{"Target":"localhost:22","TCP":"localhost:22","Interval":"10s","Timeout":"1s"}`},
+		{`{"Interval":"","Timeout":null}`, `{}`},
+	} {
+		var definition CheckDefinition
+		if err := json.Unmarshal([]byte(d.consul), &definition); err != nil {
+			t.Errorf("%s => Error: %v", d.consul, err)
+			continue
+		}
+		b, _ := json.Marshal(definition)
+		if string(b) != d.expected {
+			t.Errorf("%s => want %s, got %s", d.consul, d.expected, string(b))
+		}
+	}
+
+	var definition CheckDefinition
+	if err := json.Unmarshal([]byte(`{"Interval":"soon"}`), &definition); err == nil {
+		t.Error("Interval 'soon' => want error, got nil")
+	}
+}
+
+func TestCheckDefinitionJSONRedactsHeaders(t *testing.T) {
+	definition := CheckDefinition{
+		HTTP:   "http://localhost/health",
+		Header: map[string][]string{"Authorization": {"Bearer secret"}, "X-Token": {"token1", "token2"}},
+	}
+	b, err := json.Marshal(definition)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret", "token1", "token2"} {
+		if strings.Contains(string(b), secret) {
+			t.Errorf("%s contains the header value %s", string(b), secret)
+		}
+	}
+	expected := `"Header":{"Authorization":["xxxxx"],"X-Token":["xxxxx","xxxxx"]}`
+	if !strings.Contains(string(b), expected) {
+		t.Errorf("%s => want %s", string(b), expected)
+	}
+	if definition.Header["Authorization"][0] != "Bearer secret" {
+		t.Error("MarshalJSON modified the header")
+	}
+}
+
+func TestWithoutDefinition(t *testing.T) {
+	check := &Check{CheckID: "a", Type: "http", Definition: &CheckDefinition{HTTP: "http://localhost"}}
+	copied := check.WithoutDefinition()
+	if copied.CheckID != "a" || copied.Type != "" || copied.Definition != nil {
+		t.Errorf("WithoutDefinition => got %+v", copied)
+	}
+	if check.Type != "http" || check.Definition == nil {
+		t.Errorf("WithoutDefinition modified the check: %+v", check)
+	}
+}
@@ -17,9 +17,12 @@ package client
 import (
 	"github.com/hashicorp/go-cleanhttp"
 	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/statsd"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 var (
@@ -54,18 +57,25 @@ func init() {
 	prometheus.MustRegister(counter, histVec, inFlightGauge)
 }
 
-// CreateClient creates a new http.Client
-func CreateClient(c *config.ClientConfig) *http.Client {
+// Middleware wraps the transport of an http.Client.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// CreateClient creates a new http.Client, whose transport is wrapped by the middleware in order.
+func CreateClient(c *config.ClientConfig, middleware ...Middleware) *http.Client {
 	transport := cleanhttp.DefaultPooledTransport()
 	transport.MaxIdleConns = c.QueryMaxIdleConnectionCount
 	transport.IdleConnTimeout = c.QueryIdleConnectionTimeout
 
-	roundTripper := promhttp.InstrumentRoundTripperInFlight(inFlightGauge,
+	var roundTripper http.RoundTripper = promhttp.InstrumentRoundTripperInFlight(inFlightGauge,
 		promhttp.InstrumentRoundTripperCounter(counter,
 			promhttp.InstrumentRoundTripperDuration(histVec, transport),
 		),
 	)
 
+	for _, m := range middleware {
+		roundTripper = m(roundTripper)
+	}
+
 	client := &http.Client{
 		Transport: roundTripper,
 		Timeout:   c.QueryTimeout,
@@ -73,3 +83,28 @@ func CreateClient(c *config.ClientConfig) *http.Client {
 
 	return client
 }
+
+// StatsD is a Middleware which sends the count and latency of requests to StatsD.
+func StatsD(s statsd.Client) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &statsDRoundTripper{statsd: s, next: next}
+	}
+}
+
+type statsDRoundTripper struct {
+	statsd statsd.Client
+	next   http.RoundTripper
+}
+
+func (t *statsDRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	tags := []string{statsd.Tag("code", code), statsd.Tag("method", req.Method)}
+	t.statsd.Count("client.requests", 1, tags...)
+	t.statsd.Timing("client.request_duration", time.Since(start), tags...)
+	return resp, err
+}
@@ -0,0 +1,106 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/server"
+	"github.com/spf13/cobra"
+	"sort"
+	"strings"
+)
+
+const (
+	nagiosOk       = 0
+	nagiosWarning  = 1
+	nagiosCritical = 2
+	nagiosUnknown  = 3
+)
+
+var (
+	nagiosStates = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+	nagiosConfig = config.DefaultServerConfig() // only holds the flags, which loadServerConfig reads
+	nagiosCmd    = &cobra.Command{
+		Use:   "nagios <route>",
+		Short: "Evaluates a Consulate route as a Nagios plugin",
+		Long: `Evaluates a Consulate verify route, like /verify/service/name/web?status=warning,
+once against Consul.  The result is printed as a Nagios/Icinga plugin status line
+and the exit code is 0 (OK), 1 (WARNING), 2 (CRITICAL), or 3 (UNKNOWN).  The config
+file is loaded like the server command, with the flags taking precedence.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			var verdict *server.Verdict
+			serverConfig, err := loadServerConfig(cmd.Flags())
+			if err == nil {
+				verdict, err = server.Probe(serverConfig, args[0])
+			}
+			var state int
+			var output string
+			if err != nil {
+				state = nagiosUnknown
+				output = fmt.Sprintf("%s - %s", nagiosStates[state], err)
+			} else {
+				state = nagiosState(verdict)
+				output = nagiosOutput(state, verdict)
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), output)
+			osExit(state)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(nagiosCmd)
+
+	nagiosCmd.Flags().StringVarP(&nagiosConfig.ConsulAddress, consulAddressKey, "c", config.DefaultConsulAddress, "the Consul HTTP API address to query against")
+	nagiosCmd.Flags().DurationVar(&nagiosConfig.ClientConfig.QueryTimeout, queryTimeoutKey, config.DefaultQueryTimeout, "the maximum duration before timing out the Consul HTTP API query")
+}
+
+// nagiosState maps a server.Verdict to the Nagios plugin state.
+func nagiosState(v *server.Verdict) int {
+	switch v.Result.Status {
+	case checks.Ok:
+		return nagiosOk
+	case checks.Warning:
+		return nagiosWarning
+	case checks.Failed:
+		if v.Result.Counts != nil {
+			return nagiosCritical
+		}
+	}
+	return nagiosUnknown
+}
+
+// nagiosOutput formats a server.Verdict as a Nagios plugin status line.
+func nagiosOutput(state int, v *server.Verdict) string {
+	if v.Result.Detail != "" {
+		return fmt.Sprintf("%s - %s", nagiosStates[state], v.Result.Detail)
+	}
+	summary := fmt.Sprintf("%d passing, %d warning, %d failing",
+		v.Counts[checks.StatusPassing], v.Counts[checks.StatusWarning], v.Counts[checks.StatusFailing])
+	if state != nagiosOk && len(v.Result.Checks) > 0 {
+		var ids []string
+		for id, c := range v.Result.Checks {
+			ids = append(ids, fmt.Sprintf("%s (%s)", id, c.Status))
+		}
+		sort.Strings(ids)
+		summary = fmt.Sprintf("%s: %s", summary, strings.Join(ids, ", "))
+	}
+	return fmt.Sprintf("%s - %s | passing=%d;;;0 warning=%d;;;0 failing=%d;;;0", nagiosStates[state], summary,
+		v.Counts[checks.StatusPassing], v.Counts[checks.StatusWarning], v.Counts[checks.StatusFailing])
+}
@@ -0,0 +1,140 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/server"
+	"strings"
+	"testing"
+)
+
+var nagiosOutputData = []struct {
+	verdict server.Verdict
+	state   int
+	output  string
+}{
+	{
+		verdict: server.Verdict{
+			Counts: map[checks.Status]int{checks.StatusPassing: 2},
+			Result: checks.Result{Status: checks.Ok},
+		},
+		state:  nagiosOk,
+		output: "OK - 2 passing, 0 warning, 0 failing | passing=2;;;0 warning=0;;;0 failing=0;;;0",
+	},
+	{
+		verdict: server.Verdict{
+			Counts: map[checks.Status]int{checks.StatusPassing: 1, checks.StatusWarning: 1},
+			Result: checks.Result{
+				Status: checks.Warning,
+				Counts: map[checks.Status]int{checks.StatusPassing: 1, checks.StatusWarning: 1},
+				Checks: map[string]*checks.Check{"check1": {Status: "warning"}},
+			},
+		},
+		state:  nagiosWarning,
+		output: "WARNING - 1 passing, 1 warning, 0 failing: check1 (warning) | passing=1;;;0 warning=1;;;0 failing=0;;;0",
+	},
+	{
+		verdict: server.Verdict{
+			Counts: map[checks.Status]int{checks.StatusWarning: 1, checks.StatusFailing: 1},
+			Result: checks.Result{
+				Status: checks.Failed,
+				Counts: map[checks.Status]int{checks.StatusWarning: 1, checks.StatusFailing: 1},
+				Checks: map[string]*checks.Check{"check2": {Status: "critical"}, "check1": {Status: "warning"}},
+			},
+		},
+		state:  nagiosCritical,
+		output: "CRITICAL - 0 passing, 1 warning, 1 failing: check1 (warning), check2 (critical) | passing=0;;;0 warning=1;;;0 failing=1;;;0",
+	},
+	{
+		verdict: server.Verdict{
+			Result: checks.Result{Status: checks.NoChecks, Detail: "No checks with CheckID: unknown"},
+		},
+		state:  nagiosUnknown,
+		output: "UNKNOWN - No checks with CheckID: unknown",
+	},
+	{
+		verdict: server.Verdict{
+			Result: checks.Result{Status: checks.Failed, Detail: "connection refused"},
+		},
+		state:  nagiosUnknown,
+		output: "UNKNOWN - connection refused",
+	},
+}
+
+func TestNagiosOutput(t *testing.T) {
+	for _, d := range nagiosOutputData {
+		state := nagiosState(&d.verdict)
+		if state != d.state {
+			t.Errorf("State => got: %d, want: %d", state, d.state)
+		}
+		output := nagiosOutput(state, &d.verdict)
+		if output != d.output {
+			t.Errorf("Output =>\n  want: %s\n  got: %s", d.output, output)
+		}
+	}
+}
+
+func TestNagiosCommandWithUnsupportedRoute(t *testing.T) {
+	output := new(bytes.Buffer)
+	rootCmd.SetArgs([]string{"nagios", "/about"})
+	rootCmd.SetOut(output)
+	defer rootCmd.SetOut(nil)
+	exitCode, _, _ := executeCli(Execute)
+
+	if exitCode != nagiosUnknown {
+		t.Errorf("ExitCode => got: %d, want: %d", exitCode, nagiosUnknown)
+	}
+	expected := "UNKNOWN - unsupported route: /about\n"
+	if output.String() != expected {
+		t.Errorf("Output =>\n  want: %q\n  got: %q", expected, output.String())
+	}
+}
+
+func TestNagiosCommandWithConsulUnavailable(t *testing.T) {
+	output := new(bytes.Buffer)
+	rootCmd.SetArgs([]string{"nagios", "--consul-address", "127.0.0.1:1", "/verify/checks"})
+	rootCmd.SetOut(output)
+	defer rootCmd.SetOut(nil)
+	exitCode, _, _ := executeCli(Execute)
+
+	if exitCode != nagiosUnknown {
+		t.Errorf("ExitCode => got: %d, want: %d", exitCode, nagiosUnknown)
+	}
+	if !strings.HasPrefix(output.String(), "UNKNOWN - ") {
+		t.Errorf("Output => got: %q, want prefix: %q", output.String(), "UNKNOWN - ")
+	}
+}
+
+func TestNagiosCommandWithConfig(t *testing.T) {
+	defer readConfig(t, "consul-address: 127.0.0.1:2\n")()
+	consulAddress := nagiosCmd.Flags().Lookup(consulAddressKey)
+	consulAddress.Value.Set(config.DefaultConsulAddress)
+	consulAddress.Changed = false
+	output := new(bytes.Buffer)
+	rootCmd.SetArgs([]string{"nagios", "/verify/checks"})
+	rootCmd.SetOut(output)
+	defer rootCmd.SetOut(nil)
+	exitCode, _, _ := executeCli(Execute)
+
+	if exitCode != nagiosUnknown {
+		t.Errorf("ExitCode => got: %d, want: %d", exitCode, nagiosUnknown)
+	}
+	if !strings.HasPrefix(output.String(), "UNKNOWN - ") || !strings.Contains(output.String(), "127.0.0.1:2") {
+		t.Errorf("Output => got: %q, want the Consul address of the config file: %q", output.String(), "127.0.0.1:2")
+	}
+}
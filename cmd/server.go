@@ -15,13 +15,21 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/logging"
 	"github.com/kadaan/consulate/server"
+	"github.com/kadaan/consulate/spi"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
-	"log"
 	"os"
 	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
 )
 
 const (
@@ -42,68 +50,255 @@ const (
 	noChecksStatusCodeKey          = "no-checks-status-code"
 	unprocessableStatusCodeKey     = "unprocessable-status-code"
 	consulUnavailableStatusCodeKey = "consul-navailable-status-code"
+	unauthorizedStatusCodeKey      = "unauthorized-status-code"
+	rateLimitedStatusCodeKey       = "rate-limited-status-code"
+	dashboardRefreshIntervalKey    = "dashboard-refresh-interval"
+	profileKey                     = "profile"
+	grpcListenAddressKey           = "grpc-listen-address"
+	adminListenAddressKey          = "admin-listen-address"
+	watchIntervalKey               = "watch-interval"
+	maxWaitKey                     = "max-wait"
+	webhooksKey                    = "webhooks"
+	alertmanagerURLKey             = "alertmanager-url"
+	alertmanagerProfileKey         = "alertmanager-profile"
+	alertmanagerResendIntervalKey  = "alertmanager-resend-interval"
+	auditLogPathKey                = "audit-log-path"
+	auditLogMaxSizeKey             = "audit-log-max-size"
+	auditLogMaxBackupsKey          = "audit-log-max-backups"
+	agentCheckListenerKey          = "agent-check-listener"
+	agentCheckWarningWeightKey     = "agent-check-warning-weight"
+	tcpCheckListenerKey            = "tcp-check-listener"
+	tlsCertFileKey                 = "tls-cert-file"
+	tlsKeyFileKey                  = "tls-key-file"
+	tlsMinVersionKey               = "tls-min-version"
+	tlsCipherSuiteKey              = "tls-cipher-suite"
+	tlsReloadIntervalKey           = "tls-reload-interval"
+	tlsClientCAFileKey             = "tls-client-ca-file"
+	tlsClientPolicyKey             = "tls-client-policy"
+	authKey                        = "auth"
+	authReloadIntervalKey          = "auth-reload-interval"
+	allowKey                       = "allow"
+	denyKey                        = "deny"
+	trustedProxyKey                = "trusted-proxy"
+	rateLimitKey                   = "rate-limit"
+	rateLimitBurstKey              = "rate-limit-burst"
+	rateLimitKeyKey                = "rate-limit-key"
+	statsDAddressKey               = "statsd-address"
+	statsDPrefixKey                = "statsd-prefix"
+	statsDSampleRateKey            = "statsd-sample-rate"
+	statsDTagsKey                  = "statsd-tags"
+	statsDIntervalKey              = "statsd-interval"
+	tracingEndpointKey             = "tracing-endpoint"
+	tracingInsecureKey             = "tracing-insecure"
+	tracingSampleRatioKey          = "tracing-sample-ratio"
+	logLevelKey                    = "log-level"
+	logFormatKey                   = "log-format"
 )
 
 var (
-	serverConfig config.ServerConfig
-
-	quit      = make(chan os.Signal)
+	quit      = make(chan os.Signal, 1)
 	serverCmd = &cobra.Command{
 		Use:   "server",
 		Short: "Runs the Consulate server",
-		Long:  `Starts the Consulate server and runs until an interrupt is received.`,
+		Long: `Starts the Consulate server and runs until an interrupt, SIGTERM or SIGQUIT is received.
+On SIGHUP, the config file is reloaded and changes to the status codes, profiles, cache and
+client settings are applied.`,
 		Run: func(cmd *cobra.Command, args []string) {
-			server, err := server.NewServer(&serverConfig).Start()
+			serverConfig, err := loadServerConfig(cmd.Flags())
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return
+			}
+			logger, err := logging.NewLogger(serverConfig.LogConfig)
+			if err != nil {
+				fmt.Fprintln(cmd.ErrOrStderr(), err)
+				return
+			}
+			server, err := server.NewServer(serverConfig).Start()
 			if server != nil {
 				defer server.Stop()
 			}
 			if err != nil {
-				log.Println("Shutting down Consulate server...")
+				logger.WithError(err).Error("Failed to start Consulate server")
 			} else {
-				log.Println("Press Ctrl-C to shutdown server")
-				signal.Notify(quit, os.Interrupt)
-				<-quit
+				logger.Info("Press Ctrl-C to shutdown server")
+				signal.Notify(quit, os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGHUP)
+				defer signal.Stop(quit)
+				for sig := range quit {
+					if sig != syscall.SIGHUP {
+						logger.WithField("signal", sig).Info("Received shutdown signal")
+						break
+					}
+					reloadServer(cmd, logger, server)
+				}
 				server.Stop()
 			}
 		},
 	}
 )
 
+// reloadServer rereads the config file and applies it to the running server.
+func reloadServer(cmd *cobra.Command, logger *logrus.Logger, server spi.RunningServer) {
+	logger.Info("Reloading config file")
+	if err := viper.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			logger.WithError(err).Error("Failed to reload config file")
+			return
+		}
+	}
+	serverConfig, err := loadServerConfig(cmd.Flags())
+	if err != nil {
+		logger.WithError(err).Error("Failed to reload config")
+		return
+	}
+	if _, err := server.Reload(serverConfig); err != nil {
+		logger.WithError(err).Error("Failed to reload config")
+	}
+}
+
+// loadServerConfig creates the server config from the flags set on the command line, then the
+// config file, then the defaults.
+func loadServerConfig(flags *pflag.FlagSet) (*config.ServerConfig, error) {
+	c := &config.ServerConfig{}
+	loaded := serverFlags(c)
+	var err error
+	loaded.VisitAll(func(f *pflag.Flag) {
+		var values []string
+		if set := flags.Lookup(f.Name); set != nil && set.Changed {
+			values = flagValues(flags, set)
+		} else if viper.InConfig(f.Name) {
+			values = configValues(viper.Get(f.Name))
+		} else {
+			return
+		}
+		if setErr := setFlag(f, values); setErr != nil && err == nil {
+			err = fmt.Errorf("invalid %s: %s", f.Name, setErr)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	webhooks, err := config.DecodeWebhookConfigs(viper.Get(webhooksKey))
+	if err != nil {
+		return nil, err
+	}
+	c.Webhooks = webhooks
+	return c, nil
+}
+
+func flagValues(flags *pflag.FlagSet, f *pflag.Flag) []string {
+	if v, ok := f.Value.(pflag.SliceValue); ok {
+		return v.GetSlice()
+	}
+	if m, err := flags.GetStringToString(f.Name); err == nil {
+		return configValues(m)
+	}
+	return []string{f.Value.String()}
+}
+
+// configValues converts a config file value to flag values, with maps converted to sorted
+// key=value pairs.
+func configValues(value interface{}) []string {
+	var values []string
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, fmt.Sprint(v.Index(i).Interface()))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			values = append(values, fmt.Sprintf("%v=%v", k.Interface(), v.MapIndex(k).Interface()))
+		}
+		sort.Strings(values)
+	default:
+		values = append(values, fmt.Sprint(value))
+	}
+	return values
+}
+
+func setFlag(f *pflag.Flag, values []string) error {
+	if v, ok := f.Value.(pflag.SliceValue); ok {
+		return v.Replace(values)
+	}
+	for _, value := range values {
+		if f.Value.Type() == "stringToString" {
+			value = `"` + strings.Replace(value, `"`, `""`, -1) + `"`
+		}
+		if err := f.Value.Set(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(serverCmd)
 
-	serverCmd.Flags().StringVarP(&serverConfig.ListenAddress, listenAddressKey, "l", config.DefaultListenAddress, "the listen address")
-	viper.BindPFlag(listenAddressKey, serverCmd.Flags().Lookup(listenAddressKey))
-	serverCmd.Flags().StringVarP(&serverConfig.ConsulAddress, consulAddressKey, "c", config.DefaultConsulAddress, "the Consul HTTP API address to query against")
-	viper.BindPFlag(consulAddressKey, serverCmd.Flags().Lookup(consulAddressKey))
-	serverCmd.Flags().DurationVar(&serverConfig.CacheConfig.ConsulCacheDuration, consulCacheDurationKey, config.DefaultConsulCacheDuration, "the duration that Consul results will be cached")
-	viper.BindPFlag(consulAddressKey, serverCmd.Flags().Lookup(consulCacheDurationKey))
-	serverCmd.Flags().DurationVar(&serverConfig.ReadTimeout, readTimeoutKey, config.DefaultReadTimeout, "the maximum duration for reading the entire request")
-	viper.BindPFlag(readTimeoutKey, serverCmd.Flags().Lookup(readTimeoutKey))
-	serverCmd.Flags().DurationVar(&serverConfig.WriteTimeout, writeTimeoutKey, config.DefaultWriteTimeout, "the maximum duration before timing out writes of the response")
-	viper.BindPFlag(writeTimeoutKey, serverCmd.Flags().Lookup(writeTimeoutKey))
-	serverCmd.Flags().DurationVar(&serverConfig.ClientConfig.QueryTimeout, queryTimeoutKey, config.DefaultQueryTimeout, "the maximum duration before timing out the Consul HTTP API query")
-	viper.BindPFlag(queryTimeoutKey, serverCmd.Flags().Lookup(queryTimeoutKey))
-	serverCmd.Flags().IntVar(&serverConfig.ClientConfig.QueryMaxIdleConnectionCount, queryMaxIdleConnectionCountKey, config.DefaultQueryMaxIdleConnectionCount, "the maximum number of idle (keep-alive) Consul HTTP API query connections")
-	viper.BindPFlag(queryMaxIdleConnectionCountKey, serverCmd.Flags().Lookup(queryMaxIdleConnectionCountKey))
-	serverCmd.Flags().DurationVar(&serverConfig.ClientConfig.QueryIdleConnectionTimeout, queryIdleConnectionTimeoutKey, config.DefaultQueryIdleConnectionTimeout, "is the maximum amount of time an idle (keep-alive) Consul HTTP API query connection will remain idle before closing itself")
-	viper.BindPFlag(queryIdleConnectionTimeoutKey, serverCmd.Flags().Lookup(queryIdleConnectionTimeoutKey))
-	serverCmd.Flags().DurationVar(&serverConfig.ShutdownTimeout, shutdownTimeoutKey, config.DefaultShutdownTimeout, "the maximum duration before timing out the shutdown of the server")
-	viper.BindPFlag(shutdownTimeoutKey, serverCmd.Flags().Lookup(shutdownTimeoutKey))
-	serverCmd.Flags().IntVar(&serverConfig.SuccessStatusCode, okStatusCodeKey, config.DefaultSuccessStatusCode, "the status code returned when there are 1+ passing health checks, 0 warning health checks, and 0 failing health checks")
-	viper.BindPFlag(okStatusCodeKey, serverCmd.Flags().Lookup(okStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.PartialSuccessStatusCode, partialSuccessStatusCodeKey, config.DefaultPartialSuccessStatusCode, "the status code returned when there are 1+ passing health checks and 1+ warning health checks")
-	viper.BindPFlag(partialSuccessStatusCodeKey, serverCmd.Flags().Lookup(partialSuccessStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.WarningStatusCode, warningStatusCodeKey, config.DefaultWarningStatusCode, "the status code returned when there are 0 passing health checks and 1+ warning health checks")
-	viper.BindPFlag(warningStatusCodeKey, serverCmd.Flags().Lookup(warningStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.ErrorStatusCode, errorStatusCodeKey, config.DefaultErrorStatusCode, "the status code returned when there are 1+ failing health checks")
-	viper.BindPFlag(errorStatusCodeKey, serverCmd.Flags().Lookup(errorStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.BadRequestStatusCode, badRequestStatusCodeKey, config.DefaultBadRequestStatusCode, "the status code returned when a request to Consulate could not be understood")
-	viper.BindPFlag(badRequestStatusCodeKey, serverCmd.Flags().Lookup(badRequestStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.NoCheckStatusCode, noChecksStatusCodeKey, config.DefaultNoCheckStatusCode, "the status code returned when no Consul checks exist")
-	viper.BindPFlag(noChecksStatusCodeKey, serverCmd.Flags().Lookup(noChecksStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.UnprocessableStatusCode, unprocessableStatusCodeKey, config.DefaultUnprocessableStatusCode, "the status code returned when Consulate could not parse the response from Consul")
-	viper.BindPFlag(unprocessableStatusCodeKey, serverCmd.Flags().Lookup(unprocessableStatusCodeKey))
-	serverCmd.Flags().IntVar(&serverConfig.ConsulUnavailableStatusCode, consulUnavailableStatusCodeKey, config.DefaultConsulUnavailableStatusCode, "the status code returned when Consul did not respond promptly")
-	viper.BindPFlag(consulUnavailableStatusCodeKey, serverCmd.Flags().Lookup(consulUnavailableStatusCodeKey))
+	serverCmd.Flags().AddFlagSet(serverFlags(&config.ServerConfig{}))
+	viper.BindPFlags(serverCmd.Flags())
+}
+
+// serverFlags creates the server flags, which set their values in the config.
+func serverFlags(c *config.ServerConfig) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("server", pflag.ContinueOnError)
+	flags.StringVarP(&c.ListenAddress, listenAddressKey, "l", config.DefaultListenAddress, "the listen address")
+	flags.StringVarP(&c.ConsulAddress, consulAddressKey, "c", config.DefaultConsulAddress, "the Consul HTTP API address to query against")
+	flags.DurationVar(&c.CacheConfig.ConsulCacheDuration, consulCacheDurationKey, config.DefaultConsulCacheDuration, "the duration that Consul results will be cached")
+	flags.DurationVar(&c.ReadTimeout, readTimeoutKey, config.DefaultReadTimeout, "the maximum duration for reading the entire request")
+	flags.DurationVar(&c.WriteTimeout, writeTimeoutKey, config.DefaultWriteTimeout, "the maximum duration before timing out writes of the response")
+	flags.DurationVar(&c.ClientConfig.QueryTimeout, queryTimeoutKey, config.DefaultQueryTimeout, "the maximum duration before timing out the Consul HTTP API query")
+	flags.IntVar(&c.ClientConfig.QueryMaxIdleConnectionCount, queryMaxIdleConnectionCountKey, config.DefaultQueryMaxIdleConnectionCount, "the maximum number of idle (keep-alive) Consul HTTP API query connections")
+	flags.DurationVar(&c.ClientConfig.QueryIdleConnectionTimeout, queryIdleConnectionTimeoutKey, config.DefaultQueryIdleConnectionTimeout, "is the maximum amount of time an idle (keep-alive) Consul HTTP API query connection will remain idle before closing itself")
+	flags.DurationVar(&c.ShutdownTimeout, shutdownTimeoutKey, config.DefaultShutdownTimeout, "the maximum duration before timing out the shutdown of the server")
+	flags.IntVar(&c.SuccessStatusCode, okStatusCodeKey, config.DefaultSuccessStatusCode, "the status code returned when there are 1+ passing health checks, 0 warning health checks, and 0 failing health checks")
+	flags.IntVar(&c.PartialSuccessStatusCode, partialSuccessStatusCodeKey, config.DefaultPartialSuccessStatusCode, "the status code returned when there are 1+ passing health checks and 1+ warning health checks")
+	flags.IntVar(&c.WarningStatusCode, warningStatusCodeKey, config.DefaultWarningStatusCode, "the status code returned when there are 0 passing health checks and 1+ warning health checks")
+	flags.IntVar(&c.ErrorStatusCode, errorStatusCodeKey, config.DefaultErrorStatusCode, "the status code returned when there are 1+ failing health checks")
+	flags.IntVar(&c.BadRequestStatusCode, badRequestStatusCodeKey, config.DefaultBadRequestStatusCode, "the status code returned when a request to Consulate could not be understood")
+	flags.IntVar(&c.NoCheckStatusCode, noChecksStatusCodeKey, config.DefaultNoCheckStatusCode, "the status code returned when no Consul checks exist")
+	flags.IntVar(&c.UnprocessableStatusCode, unprocessableStatusCodeKey, config.DefaultUnprocessableStatusCode, "the status code returned when Consulate could not parse the response from Consul")
+	flags.IntVar(&c.ConsulUnavailableStatusCode, consulUnavailableStatusCodeKey, config.DefaultConsulUnavailableStatusCode, "the status code returned when Consul did not respond promptly")
+	flags.IntVar(&c.UnauthorizedStatusCode, unauthorizedStatusCodeKey, config.DefaultUnauthorizedStatusCode, "the status code returned when a request could not be authenticated")
+	flags.IntVar(&c.RateLimitedStatusCode, rateLimitedStatusCodeKey, config.DefaultRateLimitedStatusCode, "the status code returned when a client has exceeded the rate limit")
+	flags.DurationVar(&c.DashboardRefreshInterval, dashboardRefreshIntervalKey, config.DefaultDashboardRefreshInterval, "the interval at which the HTML status dashboard refreshes itself, or 0 to never refresh")
+	flags.StringToStringVar(&c.Profiles, profileKey, map[string]string{}, "a named verify route, like web=/verify/service/name/web, used by /readyz (repeatable)")
+	flags.StringVar(&c.GRPCListenAddress, grpcListenAddressKey, "", "the gRPC Health Checking Protocol listen address, which is disabled when empty")
+	flags.StringVar(&c.AdminListenAddress, adminListenAddressKey, "", "the listen address of the operational routes, which are served by the listen address when empty")
+	flags.StringSliceVar(&c.AgentCheckConfig.Listeners, agentCheckListenerKey, []string{}, "an HAProxy agent-check listen address, followed by =selector unless the selector is sent as the first line (repeatable)")
+	flags.IntVar(&c.AgentCheckConfig.WarningWeight, agentCheckWarningWeightKey, config.DefaultAgentCheckWarningWeight, "the weight percentage reported to HAProxy agent checks when checks are warning")
+	flags.StringSliceVar(&c.TCPCheckConfig.Listeners, tcpCheckListenerKey, []string{}, "a TCP check listen address followed by =selector, which accepts connections while the selector is healthy and refuses them otherwise (repeatable)")
+	flags.StringVar(&c.TLSConfig.CertFile, tlsCertFileKey, "", "the PEM encoded certificate file to serve HTTPS with, which is disabled when empty")
+	flags.StringVar(&c.TLSConfig.KeyFile, tlsKeyFileKey, "", "the PEM encoded private key file of the certificate")
+	flags.StringVar(&c.TLSConfig.MinVersion, tlsMinVersionKey, config.DefaultTLSMinVersion, "the minimum TLS version accepted: 1.0, 1.1, 1.2 or 1.3")
+	flags.StringSliceVar(&c.TLSConfig.CipherSuites, tlsCipherSuiteKey, []string{}, "a TLS 1.0-1.2 cipher suite to accept, like TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, or the Go defaults when not specified (repeatable)")
+	flags.DurationVar(&c.TLSConfig.ReloadInterval, tlsReloadIntervalKey, config.DefaultTLSReloadInterval, "the interval at which the certificate and key files are checked for changes")
+	flags.StringVar(&c.TLSConfig.ClientCAFile, tlsClientCAFileKey, "", "the PEM encoded CA bundle which client certificates are verified against, which is disabled when empty")
+	flags.StringArrayVar(&c.TLSConfig.ClientPolicies, tlsClientPolicyKey, []string{}, "a route pattern, like /ui, /verify/* or verbose, followed by =names, the comma separated common or alternative names of the client certificates allowed to request it (repeatable)")
+	flags.StringArrayVar(&c.AuthConfig.Rules, authKey, []string{}, "a route group, verify, verbose, metrics or admin, followed by =bearer:<token>, =token-file:<path> or =htpasswd:<path>, any of which authenticate requests to it (repeatable)")
+	flags.DurationVar(&c.AuthConfig.ReloadInterval, authReloadIntervalKey, config.DefaultAuthReloadInterval, "the interval at which token and htpasswd files are checked for changes")
+	flags.StringArrayVar(&c.AccessConfig.Allow, allowKey, []string{}, "a route group, verify, verbose, metrics or admin, followed by =cidrs, the comma separated CIDRs which are allowed to request it, denying every other address (repeatable)")
+	flags.StringArrayVar(&c.AccessConfig.Deny, denyKey, []string{}, "a route group, verify, verbose, metrics or admin, followed by =cidrs, the comma separated CIDRs which are denied from requesting it (repeatable)")
+	flags.StringSliceVar(&c.AccessConfig.TrustedProxies, trustedProxyKey, []string{}, "a CIDR of the proxies whose X-Forwarded-For header is trusted for the client IP (repeatable)")
+	flags.Float64Var(&c.RateLimitConfig.Rate, rateLimitKey, 0, "the number of requests per second each client can make, which is unlimited when 0")
+	flags.IntVar(&c.RateLimitConfig.Burst, rateLimitBurstKey, config.DefaultRateLimitBurst, "the number of requests each client can make at once, above the rate limit")
+	flags.StringVar(&c.RateLimitConfig.Key, rateLimitKeyKey, config.DefaultRateLimitKey, "the key of the clients which are rate limited: ip, or identity for the authenticated identity, falling back to the IP")
+	flags.DurationVar(&c.WatchInterval, watchIntervalKey, config.DefaultWatchInterval, "the interval at which Consul is polled for changes to push to watchers, event streams and blocking queries")
+	flags.DurationVar(&c.MaxWait, maxWaitKey, config.DefaultMaxWait, "the longest duration that a blocking query is held, after which the write timeout applies")
+	flags.StringVar(&c.AlertmanagerConfig.URL, alertmanagerURLKey, "", "the Alertmanager URL to send alerts for failing and warning checks to, which is disabled when empty")
+	flags.StringSliceVar(&c.AlertmanagerConfig.Profiles, alertmanagerProfileKey, []string{}, "a profile whose checks are sent to Alertmanager, or every profile when not specified (repeatable)")
+	flags.DurationVar(&c.AlertmanagerConfig.ResendInterval, alertmanagerResendIntervalKey, config.DefaultAlertmanagerResendInterval, "the interval at which firing alerts are resent to Alertmanager")
+	flags.StringVar(&c.AuditConfig.Path, auditLogPathKey, "", "the path of the audit log of changes to the status of checks and profiles, which is disabled when empty")
+	flags.IntVar(&c.AuditConfig.MaxSize, auditLogMaxSizeKey, config.DefaultAuditMaxSize, "the size in megabytes at which the audit log is rotated")
+	flags.IntVar(&c.AuditConfig.MaxBackups, auditLogMaxBackupsKey, config.DefaultAuditMaxBackups, "the number of rotated audit logs to keep")
+	flags.StringVar(&c.StatsDConfig.Address, statsDAddressKey, "", "the StatsD or DogStatsD UDP address to send metrics to, which is disabled when empty")
+	flags.StringVar(&c.StatsDConfig.Prefix, statsDPrefixKey, config.DefaultStatsDPrefix, "the prefix of the names of the metrics sent to StatsD")
+	flags.Float64Var(&c.StatsDConfig.SampleRate, statsDSampleRateKey, config.DefaultStatsDSampleRate, "the rate, between 0 and 1, at which request counters and timers are sent to StatsD")
+	flags.BoolVar(&c.StatsDConfig.Tags, statsDTagsKey, config.DefaultStatsDTags, "whether DogStatsD tags are sent, or appended to the metric names for plain StatsD")
+	flags.DurationVar(&c.StatsDConfig.Interval, statsDIntervalKey, config.DefaultStatsDInterval, "the interval at which check status gauges are sent to StatsD")
+	flags.StringVar(&c.TracingConfig.Endpoint, tracingEndpointKey, "", "the OTLP/HTTP endpoint, like localhost:4318, to export OpenTelemetry traces to, which is disabled when empty")
+	flags.BoolVar(&c.TracingConfig.Insecure, tracingInsecureKey, false, "whether traces are exported over HTTP instead of HTTPS")
+	flags.Float64Var(&c.TracingConfig.SampleRatio, tracingSampleRatioKey, config.DefaultTracingSampleRatio, "the ratio, between 0 and 1, of traces which are sampled, unless the caller has decided")
+	flags.StringVar(&c.LogConfig.Level, logLevelKey, config.DefaultLogLevel, "the minimum level of the messages which are logged: trace, debug, info, warning, error, fatal or panic")
+	flags.StringVar(&c.LogConfig.Format, logFormatKey, config.DefaultLogFormat, "the format of logged messages: text or json")
+	return flags
 }
@@ -18,13 +18,19 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/kadaan/consulate/config"
+	"github.com/spf13/viper"
+	"io/ioutil"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"reflect"
 	"strconv"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 )
 
 func TestServerCommand(t *testing.T) {
@@ -39,7 +45,7 @@ func TestServerCommand(t *testing.T) {
 	go rootCmd.Execute()
 
 	f := &failer{}
-	expected := "Started Consulate server on :8080"
+	expected := `msg=\"Started Consulate server\" address=\":8080\"`
 	retry.Run(f, func(r *retry.R) {
 		result := strconv.Quote(output.String())
 		if !strings.Contains(result, expected) {
@@ -55,6 +61,80 @@ func TestServerCommand(t *testing.T) {
 	}()
 }
 
+func TestLoadServerConfig(t *testing.T) {
+	defer readConfig(t, `
+success-status-code: 299
+error-status-code: 500
+consul-cache-duration: 5s
+trusted-proxy:
+  - 10.0.0.0/8
+  - 192.168.0.0/16
+profile:
+  web: /verify/service/name/web?status=passing
+`)()
+	flags := serverFlags(&config.ServerConfig{})
+	if err := flags.Parse([]string{"--error-status-code=502", "--allow=verify=10.0.0.0/8,192.168.0.0/16"}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadServerConfig(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.SuccessStatusCode != 299 {
+		t.Errorf("SuccessStatusCode => got: %d, want: 299", c.SuccessStatusCode)
+	}
+	if c.ErrorStatusCode != 502 {
+		t.Errorf("ErrorStatusCode => got: %d, want: 502", c.ErrorStatusCode)
+	}
+	if c.WarningStatusCode != config.DefaultWarningStatusCode {
+		t.Errorf("WarningStatusCode => got: %d, want: %d", c.WarningStatusCode, config.DefaultWarningStatusCode)
+	}
+	if c.CacheConfig.ConsulCacheDuration != 5*time.Second {
+		t.Errorf("ConsulCacheDuration => got: %s, want: 5s", c.CacheConfig.ConsulCacheDuration)
+	}
+	if want := []string{"10.0.0.0/8", "192.168.0.0/16"}; !reflect.DeepEqual(c.AccessConfig.TrustedProxies, want) {
+		t.Errorf("TrustedProxies => got: %v, want: %v", c.AccessConfig.TrustedProxies, want)
+	}
+	if want := []string{"verify=10.0.0.0/8,192.168.0.0/16"}; !reflect.DeepEqual(c.AccessConfig.Allow, want) {
+		t.Errorf("Allow => got: %v, want: %v", c.AccessConfig.Allow, want)
+	}
+	if want := map[string]string{"web": "/verify/service/name/web?status=passing"}; !reflect.DeepEqual(c.Profiles, want) {
+		t.Errorf("Profiles => got: %v, want: %v", c.Profiles, want)
+	}
+}
+
+func TestLoadServerConfigInvalid(t *testing.T) {
+	defer readConfig(t, "success-status-code: ok\n")()
+
+	_, err := loadServerConfig(serverFlags(&config.ServerConfig{}))
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid success-status-code: ") {
+		t.Errorf("Error => got: %v, want: invalid success-status-code", err)
+	}
+}
+
+// readConfig reads the content as the config file, and returns a function which restores the
+// default config.
+func readConfig(t *testing.T, content string) func() {
+	dir, err := ioutil.TempDir("", "consulate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "consulate.yaml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		os.RemoveAll(dir)
+		viper.Reset()
+		viper.BindPFlags(serverCmd.Flags())
+	}
+}
+
 type failer struct {
 	failed bool
 }
@@ -0,0 +1,34 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// AccessConfig represents the configuration of the source IP access control of the route
+// groups.  Each allow and deny rule is a route group, followed by = and comma separated CIDRs,
+// like verbose=10.0.0.0/8,192.168.0.0/16.  The client IP is taken from the X-Forwarded-For
+// header only when the request is from one of the trusted proxies.
+type AccessConfig struct {
+	Allow          []string
+	Deny           []string
+	TrustedProxies []string
+}
+
+// DefaultAccessConfig gets a default AccessConfig.
+func DefaultAccessConfig() *AccessConfig {
+	return &AccessConfig{
+		Allow:          []string{},
+		Deny:           []string{},
+		TrustedProxies: []string{},
+	}
+}
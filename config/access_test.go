@@ -0,0 +1,30 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultAccessConfig(t *testing.T) {
+	c := DefaultAccessConfig()
+	if len(c.Allow) != 0 {
+		t.Errorf("Allow: want empty, got %v", c.Allow)
+	}
+	if len(c.Deny) != 0 {
+		t.Errorf("Deny: want empty, got %v", c.Deny)
+	}
+	if len(c.TrustedProxies) != 0 {
+		t.Errorf("TrustedProxies: want empty, got %v", c.TrustedProxies)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+const (
+	// DefaultAgentCheckWarningWeight is the default weight, as a percentage, reported to HAProxy agent checks when the checks are warning.
+	DefaultAgentCheckWarningWeight = 50
+)
+
+// AgentCheckConfig represents the configuration of the HAProxy agent-check listeners.  Each
+// listener is an address, optionally followed by = and the selector which it reports; without a
+// selector, the agent check sends the selector as the first line.
+type AgentCheckConfig struct {
+	Listeners     []string
+	WarningWeight int
+}
+
+// DefaultAgentCheckConfig gets a default AgentCheckConfig.
+func DefaultAgentCheckConfig() *AgentCheckConfig {
+	return &AgentCheckConfig{
+		Listeners:     []string{},
+		WarningWeight: DefaultAgentCheckWarningWeight,
+	}
+}
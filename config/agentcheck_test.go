@@ -0,0 +1,27 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultAgentCheckConfig(t *testing.T) {
+	c := DefaultAgentCheckConfig()
+	if len(c.Listeners) != 0 {
+		t.Errorf("Listeners: want empty, got %v", c.Listeners)
+	}
+	if c.WarningWeight != DefaultAgentCheckWarningWeight {
+		t.Errorf("WarningWeight: want %v, got %v", DefaultAgentCheckWarningWeight, c.WarningWeight)
+	}
+}
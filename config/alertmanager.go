@@ -0,0 +1,39 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "time"
+
+const (
+	// DefaultAlertmanagerResendInterval is the default interval at which firing alerts are resent to Alertmanager.
+	DefaultAlertmanagerResendInterval = 1 * time.Minute
+)
+
+// AlertmanagerConfig represents the configuration of sending alerts for failing and warning
+// checks to Alertmanager.  Alerts are not sent when the URL is empty, and every profile is
+// selected when no profiles are specified.
+type AlertmanagerConfig struct {
+	URL            string
+	Profiles       []string
+	ResendInterval time.Duration
+}
+
+// DefaultAlertmanagerConfig gets a default AlertmanagerConfig.
+func DefaultAlertmanagerConfig() *AlertmanagerConfig {
+	return &AlertmanagerConfig{
+		Profiles:       []string{},
+		ResendInterval: DefaultAlertmanagerResendInterval,
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultAlertmanagerConfig(t *testing.T) {
+	c := DefaultAlertmanagerConfig()
+	if c.URL != "" {
+		t.Errorf("URL: want empty, got %v", c.URL)
+	}
+	if len(c.Profiles) != 0 {
+		t.Errorf("Profiles: want empty, got %v", c.Profiles)
+	}
+	if c.ResendInterval != DefaultAlertmanagerResendInterval {
+		t.Errorf("ResendInterval: want %v, got %v", DefaultAlertmanagerResendInterval, c.ResendInterval)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+const (
+	// DefaultAuditMaxSize is the default size, in megabytes, at which the audit log is rotated.
+	DefaultAuditMaxSize = 100
+
+	// DefaultAuditMaxBackups is the default number of rotated audit logs which are kept.
+	DefaultAuditMaxBackups = 5
+)
+
+// AuditConfig represents the configuration of the audit log, which records every change in the
+// status of the Consul checks and profiles as JSON lines.  The audit log is not written when the
+// path is empty.
+type AuditConfig struct {
+	Path       string
+	MaxSize    int
+	MaxBackups int
+}
+
+// DefaultAuditConfig gets a default AuditConfig.
+func DefaultAuditConfig() *AuditConfig {
+	return &AuditConfig{
+		MaxSize:    DefaultAuditMaxSize,
+		MaxBackups: DefaultAuditMaxBackups,
+	}
+}
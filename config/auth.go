@@ -0,0 +1,39 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "time"
+
+const (
+	// DefaultAuthReloadInterval is the default interval at which token and htpasswd files are checked for changes.
+	DefaultAuthReloadInterval = 1 * time.Minute
+)
+
+// AuthConfig represents the configuration of authenticating requests to the route groups.  Each
+// rule is a route group, followed by = and an authentication method and its value, like
+// verbose=bearer:<token>, metrics=token-file:<path> or admin=htpasswd:<path>.  A request to a
+// group with rules must satisfy one of them, while groups without rules are open.
+type AuthConfig struct {
+	Rules          []string
+	ReloadInterval time.Duration
+}
+
+// DefaultAuthConfig gets a default AuthConfig.
+func DefaultAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		Rules:          []string{},
+		ReloadInterval: DefaultAuthReloadInterval,
+	}
+}
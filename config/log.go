@@ -0,0 +1,44 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+const (
+	// DefaultLogLevel is the default minimum level of the messages which are logged.
+	DefaultLogLevel = "info"
+
+	// DefaultLogFormat is the default format of logged messages.
+	DefaultLogFormat = LogFormatText
+
+	// LogFormatText is the format of logged messages as key=value text.
+	LogFormatText = "text"
+
+	// LogFormatJSON is the format of logged messages as JSON objects.
+	LogFormatJSON = "json"
+)
+
+// LogConfig represents the configuration of logging.  The level is one of trace, debug, info,
+// warning, error, fatal or panic, and the format is text or json.
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+// DefaultLogConfig gets a default LogConfig.
+func DefaultLogConfig() *LogConfig {
+	return &LogConfig{
+		Level:  DefaultLogLevel,
+		Format: DefaultLogFormat,
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultLogConfig(t *testing.T) {
+	c := DefaultLogConfig()
+	if c.Level != DefaultLogLevel {
+		t.Errorf("Level: want %v, got %v", DefaultLogLevel, c.Level)
+	}
+	if c.Format != DefaultLogFormat {
+		t.Errorf("Format: want %v, got %v", DefaultLogFormat, c.Format)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+const (
+	// DefaultRateLimitBurst is the default number of requests a client can make at once.
+	DefaultRateLimitBurst = 10
+
+	// DefaultRateLimitKey is the default key of the clients which are rate limited.
+	DefaultRateLimitKey = "ip"
+)
+
+// RateLimitConfig represents the configuration of the per-client token bucket rate limiting.
+// Clients are keyed by their IP, or with a Key of identity, by their authenticated identity,
+// falling back to their IP for route groups without authentication.
+type RateLimitConfig struct {
+	Rate  float64
+	Burst int
+	Key   string
+}
+
+// DefaultRateLimitConfig gets a default RateLimitConfig, which is disabled.
+func DefaultRateLimitConfig() *RateLimitConfig {
+	return &RateLimitConfig{
+		Burst: DefaultRateLimitBurst,
+		Key:   DefaultRateLimitKey,
+	}
+}
@@ -58,11 +58,28 @@ const (
 
 	// DefaultConsulUnavailableStatusCode (504) is the default status code returned when Consul did not respond promptly.
 	DefaultConsulUnavailableStatusCode = http.StatusGatewayTimeout
+
+	// DefaultUnauthorizedStatusCode (401) is the default status code returned when a request to Consulate could not be authenticated.
+	DefaultUnauthorizedStatusCode = http.StatusUnauthorized
+
+	// DefaultRateLimitedStatusCode (429) is the default status code returned when a client has exceeded the rate limit.
+	DefaultRateLimitedStatusCode = http.StatusTooManyRequests
+
+	// DefaultDashboardRefreshInterval is the default interval at which the HTML status dashboard refreshes itself.
+	DefaultDashboardRefreshInterval = 10 * time.Second
+
+	// DefaultWatchInterval is the default interval at which Consul is polled for changes to push to watchers, event streams and blocking queries.
+	DefaultWatchInterval = 1 * time.Second
+
+	// DefaultMaxWait is the default longest duration that a blocking query is held.
+	DefaultMaxWait = 5 * time.Minute
 )
 
 // ServerConfig represents the configuration of the Consulate server.
 type ServerConfig struct {
 	ListenAddress               string
+	GRPCListenAddress           string
+	AdminListenAddress          string
 	ConsulAddress               string
 	ReadTimeout                 time.Duration
 	WriteTimeout                time.Duration
@@ -75,8 +92,26 @@ type ServerConfig struct {
 	NoCheckStatusCode           int
 	UnprocessableStatusCode     int
 	ConsulUnavailableStatusCode int
+	UnauthorizedStatusCode      int
+	RateLimitedStatusCode       int
+	DashboardRefreshInterval    time.Duration
+	WatchInterval               time.Duration
+	MaxWait                     time.Duration
+	Profiles                    map[string]string
+	Webhooks                    []WebhookConfig
 	ClientConfig                ClientConfig
 	CacheConfig                 CacheConfig
+	AlertmanagerConfig          AlertmanagerConfig
+	StatsDConfig                StatsDConfig
+	TracingConfig               TracingConfig
+	LogConfig                   LogConfig
+	AuditConfig                 AuditConfig
+	AgentCheckConfig            AgentCheckConfig
+	TCPCheckConfig              TCPCheckConfig
+	TLSConfig                   TLSConfig
+	AuthConfig                  AuthConfig
+	AccessConfig                AccessConfig
+	RateLimitConfig             RateLimitConfig
 }
 
 // DefaultServerConfig gets a default ServerConfig.
@@ -95,7 +130,24 @@ func DefaultServerConfig() *ServerConfig {
 		NoCheckStatusCode:           DefaultNoCheckStatusCode,
 		UnprocessableStatusCode:     DefaultUnprocessableStatusCode,
 		ConsulUnavailableStatusCode: DefaultConsulUnavailableStatusCode,
+		UnauthorizedStatusCode:      DefaultUnauthorizedStatusCode,
+		RateLimitedStatusCode:       DefaultRateLimitedStatusCode,
+		DashboardRefreshInterval:    DefaultDashboardRefreshInterval,
+		WatchInterval:               DefaultWatchInterval,
+		MaxWait:                     DefaultMaxWait,
+		Profiles:                    map[string]string{},
 		ClientConfig:                *DefaultClientConfig(),
 		CacheConfig:                 *DefaultCacheConfig(),
+		AlertmanagerConfig:          *DefaultAlertmanagerConfig(),
+		StatsDConfig:                *DefaultStatsDConfig(),
+		TracingConfig:               *DefaultTracingConfig(),
+		LogConfig:                   *DefaultLogConfig(),
+		AuditConfig:                 *DefaultAuditConfig(),
+		AgentCheckConfig:            *DefaultAgentCheckConfig(),
+		TCPCheckConfig:              *DefaultTCPCheckConfig(),
+		TLSConfig:                   *DefaultTLSConfig(),
+		AuthConfig:                  *DefaultAuthConfig(),
+		AccessConfig:                *DefaultAccessConfig(),
+		RateLimitConfig:             *DefaultRateLimitConfig(),
 	}
 }
@@ -23,6 +23,9 @@ func TestDefaultServerConfig(t *testing.T) {
 	if c.ListenAddress != DefaultListenAddress {
 		t.Errorf("ListenAddress: want %v, got %v", DefaultListenAddress, c.ListenAddress)
 	}
+	if c.GRPCListenAddress != "" {
+		t.Errorf("GRPCListenAddress: want empty, got %v", c.GRPCListenAddress)
+	}
 	if c.ConsulAddress != DefaultConsulAddress {
 		t.Errorf("ConsulAddress: want %v, got %v", DefaultConsulAddress, c.ConsulAddress)
 	}
@@ -59,4 +62,52 @@ func TestDefaultServerConfig(t *testing.T) {
 	if c.ConsulUnavailableStatusCode != DefaultConsulUnavailableStatusCode {
 		t.Errorf("ConsulUnavailableStatusCode: want %v, got %v", DefaultConsulUnavailableStatusCode, c.ConsulUnavailableStatusCode)
 	}
+	if c.UnauthorizedStatusCode != DefaultUnauthorizedStatusCode {
+		t.Errorf("UnauthorizedStatusCode: want %v, got %v", DefaultUnauthorizedStatusCode, c.UnauthorizedStatusCode)
+	}
+	if c.RateLimitedStatusCode != DefaultRateLimitedStatusCode {
+		t.Errorf("RateLimitedStatusCode: want %v, got %v", DefaultRateLimitedStatusCode, c.RateLimitedStatusCode)
+	}
+	if c.DashboardRefreshInterval != DefaultDashboardRefreshInterval {
+		t.Errorf("DashboardRefreshInterval: want %v, got %v", DefaultDashboardRefreshInterval, c.DashboardRefreshInterval)
+	}
+	if c.WatchInterval != DefaultWatchInterval {
+		t.Errorf("WatchInterval: want %v, got %v", DefaultWatchInterval, c.WatchInterval)
+	}
+	if len(c.Profiles) != 0 {
+		t.Errorf("Profiles: want empty, got %v", c.Profiles)
+	}
+	if len(c.Webhooks) != 0 {
+		t.Errorf("Webhooks: want empty, got %v", c.Webhooks)
+	}
+	if c.AlertmanagerConfig.ResendInterval != DefaultAlertmanagerResendInterval {
+		t.Errorf("AlertmanagerConfig.ResendInterval: want %v, got %v", DefaultAlertmanagerResendInterval, c.AlertmanagerConfig.ResendInterval)
+	}
+	if c.StatsDConfig.Prefix != DefaultStatsDPrefix {
+		t.Errorf("StatsDConfig.Prefix: want %v, got %v", DefaultStatsDPrefix, c.StatsDConfig.Prefix)
+	}
+	if c.TracingConfig.SampleRatio != DefaultTracingSampleRatio {
+		t.Errorf("TracingConfig.SampleRatio: want %v, got %v", DefaultTracingSampleRatio, c.TracingConfig.SampleRatio)
+	}
+	if c.LogConfig.Level != DefaultLogLevel {
+		t.Errorf("LogConfig.Level: want %v, got %v", DefaultLogLevel, c.LogConfig.Level)
+	}
+	if c.AuditConfig.MaxSize != DefaultAuditMaxSize {
+		t.Errorf("AuditConfig.MaxSize: want %v, got %v", DefaultAuditMaxSize, c.AuditConfig.MaxSize)
+	}
+	if c.AgentCheckConfig.WarningWeight != DefaultAgentCheckWarningWeight {
+		t.Errorf("AgentCheckConfig.WarningWeight: want %v, got %v", DefaultAgentCheckWarningWeight, c.AgentCheckConfig.WarningWeight)
+	}
+	if len(c.TCPCheckConfig.Listeners) != 0 {
+		t.Errorf("TCPCheckConfig.Listeners: want empty, got %v", c.TCPCheckConfig.Listeners)
+	}
+	if c.TLSConfig.MinVersion != DefaultTLSMinVersion {
+		t.Errorf("TLSConfig.MinVersion: want %v, got %v", DefaultTLSMinVersion, c.TLSConfig.MinVersion)
+	}
+	if c.AuthConfig.ReloadInterval != DefaultAuthReloadInterval {
+		t.Errorf("AuthConfig.ReloadInterval: want %v, got %v", DefaultAuthReloadInterval, c.AuthConfig.ReloadInterval)
+	}
+	if c.RateLimitConfig.Burst != DefaultRateLimitBurst {
+		t.Errorf("RateLimitConfig.Burst: want %v, got %v", DefaultRateLimitBurst, c.RateLimitConfig.Burst)
+	}
 }
@@ -0,0 +1,52 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "time"
+
+const (
+	// DefaultStatsDPrefix is the default prefix of the names of the metrics sent to StatsD.
+	DefaultStatsDPrefix = "consulate."
+
+	// DefaultStatsDSampleRate is the default rate at which counters and timers are sampled.
+	DefaultStatsDSampleRate = 1.0
+
+	// DefaultStatsDTags is whether DogStatsD tags are sent by default.
+	DefaultStatsDTags = true
+
+	// DefaultStatsDInterval is the default interval at which check status gauges are sent to StatsD.
+	DefaultStatsDInterval = 10 * time.Second
+)
+
+// StatsDConfig represents the configuration of sending metrics to a StatsD or DogStatsD server.
+// Metrics are not sent when the address is empty.  When tags are disabled, for plain StatsD
+// servers, the tag values are appended to the metric names instead.
+type StatsDConfig struct {
+	Address    string
+	Prefix     string
+	SampleRate float64
+	Tags       bool
+	Interval   time.Duration
+}
+
+// DefaultStatsDConfig gets a default StatsDConfig.
+func DefaultStatsDConfig() *StatsDConfig {
+	return &StatsDConfig{
+		Prefix:     DefaultStatsDPrefix,
+		SampleRate: DefaultStatsDSampleRate,
+		Tags:       DefaultStatsDTags,
+		Interval:   DefaultStatsDInterval,
+	}
+}
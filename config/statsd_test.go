@@ -0,0 +1,36 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultStatsDConfig(t *testing.T) {
+	c := DefaultStatsDConfig()
+	if c.Address != "" {
+		t.Errorf("Address: want empty, got %v", c.Address)
+	}
+	if c.Prefix != DefaultStatsDPrefix {
+		t.Errorf("Prefix: want %v, got %v", DefaultStatsDPrefix, c.Prefix)
+	}
+	if c.SampleRate != DefaultStatsDSampleRate {
+		t.Errorf("SampleRate: want %v, got %v", DefaultStatsDSampleRate, c.SampleRate)
+	}
+	if c.Tags != DefaultStatsDTags {
+		t.Errorf("Tags: want %v, got %v", DefaultStatsDTags, c.Tags)
+	}
+	if c.Interval != DefaultStatsDInterval {
+		t.Errorf("Interval: want %v, got %v", DefaultStatsDInterval, c.Interval)
+	}
+}
@@ -0,0 +1,28 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// TCPCheckConfig represents the configuration of the TCP check listeners for layer 4 load
+// balancers.  Each listener is an address followed by = and the selector which it follows.
+type TCPCheckConfig struct {
+	Listeners []string
+}
+
+// DefaultTCPCheckConfig gets a default TCPCheckConfig.
+func DefaultTCPCheckConfig() *TCPCheckConfig {
+	return &TCPCheckConfig{
+		Listeners: []string{},
+	}
+}
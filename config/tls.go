@@ -0,0 +1,50 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "time"
+
+const (
+	// DefaultTLSMinVersion is the default minimum TLS version accepted by Consulate.
+	DefaultTLSMinVersion = "1.2"
+
+	// DefaultTLSReloadInterval is the default interval at which the certificate and key files are checked for changes.
+	DefaultTLSReloadInterval = 1 * time.Minute
+)
+
+// TLSConfig represents the configuration of serving HTTPS.  Consulate serves HTTP when the
+// certificate and key files are empty, and the default cipher suites of Go are used when no
+// cipher suites are specified.  Client certificates are verified against the client CA file,
+// when specified, and each client policy is a route pattern followed by = and the comma
+// separated names which client certificates must have to request the matching routes.
+type TLSConfig struct {
+	CertFile       string
+	KeyFile        string
+	MinVersion     string
+	CipherSuites   []string
+	ReloadInterval time.Duration
+	ClientCAFile   string
+	ClientPolicies []string
+}
+
+// DefaultTLSConfig gets a default TLSConfig.
+func DefaultTLSConfig() *TLSConfig {
+	return &TLSConfig{
+		MinVersion:     DefaultTLSMinVersion,
+		CipherSuites:   []string{},
+		ReloadInterval: DefaultTLSReloadInterval,
+		ClientPolicies: []string{},
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultTLSConfig(t *testing.T) {
+	c := DefaultTLSConfig()
+	if c.CertFile != "" {
+		t.Errorf("CertFile: want empty, got %v", c.CertFile)
+	}
+	if c.KeyFile != "" {
+		t.Errorf("KeyFile: want empty, got %v", c.KeyFile)
+	}
+	if c.MinVersion != DefaultTLSMinVersion {
+		t.Errorf("MinVersion: want %v, got %v", DefaultTLSMinVersion, c.MinVersion)
+	}
+	if len(c.CipherSuites) != 0 {
+		t.Errorf("CipherSuites: want empty, got %v", c.CipherSuites)
+	}
+	if c.ReloadInterval != DefaultTLSReloadInterval {
+		t.Errorf("ReloadInterval: want %v, got %v", DefaultTLSReloadInterval, c.ReloadInterval)
+	}
+	if c.ClientCAFile != "" {
+		t.Errorf("ClientCAFile: want empty, got %v", c.ClientCAFile)
+	}
+	if len(c.ClientPolicies) != 0 {
+		t.Errorf("ClientPolicies: want empty, got %v", c.ClientPolicies)
+	}
+}
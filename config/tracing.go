@@ -0,0 +1,35 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+const (
+	// DefaultTracingSampleRatio is the default ratio of traces which are sampled.
+	DefaultTracingSampleRatio = 1.0
+)
+
+// TracingConfig represents the configuration of exporting OpenTelemetry traces over OTLP/HTTP.
+// Traces are not exported when the endpoint is empty.
+type TracingConfig struct {
+	Endpoint    string
+	Insecure    bool
+	SampleRatio float64
+}
+
+// DefaultTracingConfig gets a default TracingConfig.
+func DefaultTracingConfig() *TracingConfig {
+	return &TracingConfig{
+		SampleRatio: DefaultTracingSampleRatio,
+	}
+}
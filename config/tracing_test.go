@@ -0,0 +1,30 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import "testing"
+
+func TestDefaultTracingConfig(t *testing.T) {
+	c := DefaultTracingConfig()
+	if c.Endpoint != "" {
+		t.Errorf("Endpoint: want empty, got %v", c.Endpoint)
+	}
+	if c.Insecure {
+		t.Errorf("Insecure: want false, got %v", c.Insecure)
+	}
+	if c.SampleRatio != DefaultTracingSampleRatio {
+		t.Errorf("SampleRatio: want %v, got %v", DefaultTracingSampleRatio, c.SampleRatio)
+	}
+}
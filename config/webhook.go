@@ -0,0 +1,93 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultWebhookMethod is the default HTTP method used to send webhooks.
+	DefaultWebhookMethod = http.MethodPost
+
+	// DefaultWebhookMaxRetries is the default number of times a failed webhook is retried.
+	DefaultWebhookMaxRetries = 5
+
+	// DefaultWebhookInitialBackoff is the default duration before a failed webhook is first retried.
+	DefaultWebhookInitialBackoff = 1 * time.Second
+
+	// DefaultWebhookMaxBackoff is the default maximum duration between retries of a failed webhook.
+	DefaultWebhookMaxBackoff = 1 * time.Minute
+)
+
+// WebhookConfig represents an HTTP webhook which is sent when the status of a selector changes.
+// Selectors are verify routes, like /verify/service/name/web, and profiles are the names of
+// configured profiles.  When neither are specified, every profile is selected.
+type WebhookConfig struct {
+	Name           string            `mapstructure:"name"`
+	URL            string            `mapstructure:"url"`
+	Method         string            `mapstructure:"method"`
+	Headers        map[string]string `mapstructure:"headers"`
+	Body           string            `mapstructure:"body"`
+	Selectors      []string          `mapstructure:"selectors"`
+	Profiles       []string          `mapstructure:"profiles"`
+	MaxRetries     int               `mapstructure:"max-retries"`
+	InitialBackoff time.Duration     `mapstructure:"initial-backoff"`
+	MaxBackoff     time.Duration     `mapstructure:"max-backoff"`
+}
+
+// DefaultWebhookConfig gets a default WebhookConfig.
+func DefaultWebhookConfig() *WebhookConfig {
+	return &WebhookConfig{
+		Method:         DefaultWebhookMethod,
+		Headers:        map[string]string{},
+		MaxRetries:     DefaultWebhookMaxRetries,
+		InitialBackoff: DefaultWebhookInitialBackoff,
+		MaxBackoff:     DefaultWebhookMaxBackoff,
+	}
+}
+
+// DecodeWebhookConfigs decodes the list of webhooks from a config file, applying the defaults to
+// the settings which each webhook does not specify.
+func DecodeWebhookConfigs(raw interface{}) ([]WebhookConfig, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	var items []interface{}
+	if err := mapstructure.Decode(raw, &items); err != nil {
+		return nil, errors.Wrap(err, "invalid webhooks")
+	}
+	webhooks := make([]WebhookConfig, 0, len(items))
+	for i, item := range items {
+		webhook := DefaultWebhookConfig()
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+			ErrorUnused:      true,
+			WeaklyTypedInput: true,
+			Result:           webhook,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(item); err != nil {
+			return nil, errors.Wrapf(err, "invalid webhook %d", i)
+		}
+		webhooks = append(webhooks, *webhook)
+	}
+	return webhooks, nil
+}
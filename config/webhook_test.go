@@ -0,0 +1,78 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultWebhookConfig(t *testing.T) {
+	c := DefaultWebhookConfig()
+	if c.Method != DefaultWebhookMethod {
+		t.Errorf("Method: want %v, got %v", DefaultWebhookMethod, c.Method)
+	}
+	if len(c.Headers) != 0 {
+		t.Errorf("Headers: want empty, got %v", c.Headers)
+	}
+	if c.MaxRetries != DefaultWebhookMaxRetries {
+		t.Errorf("MaxRetries: want %v, got %v", DefaultWebhookMaxRetries, c.MaxRetries)
+	}
+	if c.InitialBackoff != DefaultWebhookInitialBackoff {
+		t.Errorf("InitialBackoff: want %v, got %v", DefaultWebhookInitialBackoff, c.InitialBackoff)
+	}
+	if c.MaxBackoff != DefaultWebhookMaxBackoff {
+		t.Errorf("MaxBackoff: want %v, got %v", DefaultWebhookMaxBackoff, c.MaxBackoff)
+	}
+}
+
+func TestDecodeWebhookConfigs(t *testing.T) {
+	webhooks, err := DecodeWebhookConfigs([]interface{}{
+		map[interface{}]interface{}{
+			"name":            "chat",
+			"url":             "http://localhost/hook",
+			"headers":         map[interface{}]interface{}{"X-Token": "secret"},
+			"profiles":        []interface{}{"web"},
+			"max-retries":     "2",
+			"initial-backoff": "10ms",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(webhooks) != 1 {
+		t.Fatalf("want 1 webhook, got %d", len(webhooks))
+	}
+	c := webhooks[0]
+	if c.Name != "chat" || c.URL != "http://localhost/hook" || c.Headers["X-Token"] != "secret" {
+		t.Errorf("unexpected webhook: %+v", c)
+	}
+	if len(c.Profiles) != 1 || c.Profiles[0] != "web" {
+		t.Errorf("Profiles: want [web], got %v", c.Profiles)
+	}
+	if c.MaxRetries != 2 {
+		t.Errorf("MaxRetries: want 2, got %v", c.MaxRetries)
+	}
+	if c.InitialBackoff != 10*time.Millisecond {
+		t.Errorf("InitialBackoff: want 10ms, got %v", c.InitialBackoff)
+	}
+	if c.Method != DefaultWebhookMethod || c.MaxBackoff != DefaultWebhookMaxBackoff {
+		t.Errorf("defaults not applied: %+v", c)
+	}
+
+	if _, err := DecodeWebhookConfigs([]interface{}{map[string]interface{}{"unknown": "x"}}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"github.com/kadaan/consulate/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"log"
+)
+
+// NewLogger creates a new logrus.Logger with the configured level and format, which writes to
+// the output of the standard logger.
+func NewLogger(c config.LogConfig) (*logrus.Logger, error) {
+	return newLogger(c, log.Writer())
+}
+
+func newLogger(c config.LogConfig, out io.Writer) (*logrus.Logger, error) {
+	level, err := logrus.ParseLevel(c.Level)
+	if err != nil {
+		return nil, errors.Errorf("unsupported log level: %s", c.Level)
+	}
+	logger := logrus.New()
+	logger.SetOutput(out)
+	logger.SetLevel(level)
+	switch c.Format {
+	case config.LogFormatText:
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case config.LogFormatJSON:
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return nil, errors.Errorf("unsupported log format: %s", c.Format)
+	}
+	return logger, nil
+}
@@ -0,0 +1,66 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/kadaan/consulate/config"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var tests = []struct {
+		level    string
+		format   string
+		expected string
+	}{
+		{"info", "json", ""},
+		{"info", "text", ""},
+		{"verbose", "json", "unsupported log level: verbose"},
+		{"info", "xml", "unsupported log format: xml"},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		_, err := newLogger(config.LogConfig{Level: test.level, Format: test.format}, &out)
+		if test.expected == "" && err != nil {
+			t.Errorf("%s/%s: unexpected error: %v", test.level, test.format, err)
+		} else if test.expected != "" && (err == nil || err.Error() != test.expected) {
+			t.Errorf("%s/%s: want error %q, got %v", test.level, test.format, test.expected, err)
+		}
+	}
+}
+
+func TestNewLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := newLogger(config.LogConfig{Level: "warning", Format: config.LogFormatJSON}, &out)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logger.Info("Not logged")
+	logger.WithField("status", 503).Warn("Logged")
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want 1 line, got %d: %s", len(lines), out.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("Failed to parse %s: %v", lines[0], err)
+	}
+	if entry["msg"] != "Logged" || entry["level"] != "warning" || entry["status"] != float64(503) {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/statsd"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	forwardedForHeader  = "X-Forwarded-For"
+	allowedAccessResult = "allowed"
+	deniedAccessResult  = "denied"
+	limitedAccessResult = "limited"
+)
+
+// accessControl allows and denies requests to the route groups by their client IP.  A request
+// is denied when its client IP is in a denied CIDR of its route group, or when its route group
+// has allowed CIDRs, and its client IP is not in any of them.
+type accessControl struct {
+	allow map[string][]*net.IPNet
+	deny  map[string][]*net.IPNet
+}
+
+func (r *server) createAccessControl() error {
+	proxies, err := parseCIDRs(r.config().AccessConfig.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "invalid trusted proxies")
+	}
+	r.trustedProxies = proxies
+	allow, err := parseAccessRules(r.config().AccessConfig.Allow)
+	if err != nil {
+		return err
+	}
+	deny, err := parseAccessRules(r.config().AccessConfig.Deny)
+	if err != nil {
+		return err
+	}
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	r.access = &accessControl{allow: allow, deny: deny}
+	r.accessRequests = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "consulate",
+		Name:      "access_requests_total",
+		Help:      "Total number of HTTP requests which were allowed or denied by their client IP.",
+	}, []string{"group", "result"})).(*prometheus.CounterVec)
+	return nil
+}
+
+func parseAccessRules(rules []string) (map[string][]*net.IPNet, error) {
+	cidrs := make(map[string][]*net.IPNet)
+	for _, rule := range rules {
+		group, value, err := splitGroupRule(rule)
+		if err == nil && value == "" {
+			err = errors.New("no CIDRs")
+		}
+		var parsed []*net.IPNet
+		if err == nil {
+			parsed, err = parseCIDRs(strings.Split(value, ","))
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid access rule %q", rule)
+		}
+		cidrs[group] = append(cidrs[group], parsed...)
+	}
+	return cidrs, nil
+}
+
+// parseCIDRs parses the CIDRs, where an IP without a prefix length is a single address.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, errors.Errorf("unsupported CIDR: %s", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			cidrs = append(cidrs, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, errors.Errorf("unsupported CIDR: %s", value)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+func containsIP(cidrs []*net.IPNet, ip net.IP) bool {
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP gets the IP of the client, which is the remote address, unless it is a trusted
+// proxy.  Then, X-Forwarded-For is followed from the right, past any other trusted proxies, to
+// the address which the nearest trusted proxy received the request from.
+func (r *server) clientIP(req *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !containsIP(r.trustedProxies, ip) {
+		return ip
+	}
+	var forwarded []string
+	for _, header := range req.Header.Values(forwardedForHeader) {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		next := net.ParseIP(strings.TrimSpace(forwarded[i]))
+		if next == nil {
+			break
+		}
+		ip = next
+		if !containsIP(r.trustedProxies, ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// accessMiddleware responds with 403 Forbidden when the client IP is denied access to the
+// route group of the request.
+func (r *server) accessMiddleware(context *gin.Context) {
+	group := routeGroup(context.Request)
+	allow, deny := r.access.allow[group], r.access.deny[group]
+	if len(allow) == 0 && len(deny) == 0 {
+		context.Next()
+		return
+	}
+	ip := r.clientIP(context.Request)
+	if containsIP(deny, ip) || (len(allow) > 0 && !containsIP(allow, ip)) {
+		r.countAccess(r.accessRequests, "access_requests", group, deniedAccessResult)
+		r.respond(context, Verdict{StatusCode: http.StatusForbidden,
+			Result: checks.Result{Status: checks.Failed, Detail: "Address not allowed: " + ip.String()}})
+		return
+	}
+	r.countAccess(r.accessRequests, "access_requests", group, allowedAccessResult)
+	context.Next()
+}
+
+// countAccess counts an access decision in Prometheus and StatsD.
+func (r *server) countAccess(counter *prometheus.CounterVec, name string, group string, result string) {
+	counter.WithLabelValues(group, result).Inc()
+	r.statsd.Count(name, 1, statsd.Tag("group", group), statsd.Tag("result", result))
+}
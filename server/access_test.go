@@ -0,0 +1,75 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseCIDRs([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &server{trustedProxies: proxies}
+	for _, d := range []struct {
+		remote    string
+		forwarded []string
+		expected  string
+	}{
+		{"198.51.100.1:1234", nil, "198.51.100.1"},
+		{"198.51.100.1:1234", []string{"203.0.113.1"}, "198.51.100.1"},
+		{"192.0.2.1:1234", nil, "192.0.2.1"},
+		{"192.0.2.1:1234", []string{"203.0.113.1"}, "203.0.113.1"},
+		{"192.0.2.1:1234", []string{"203.0.113.2, 203.0.113.1"}, "203.0.113.1"},
+		{"192.0.2.1:1234", []string{"203.0.113.1, 10.0.0.2"}, "203.0.113.1"},
+		{"192.0.2.1:1234", []string{"203.0.113.1", "10.0.0.2"}, "203.0.113.1"},
+		{"192.0.2.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"192.0.2.1:1234", []string{"203.0.113.1, invalid"}, "192.0.2.1"},
+	} {
+		req := httptest.NewRequest("GET", "/verify/checks", nil)
+		req.RemoteAddr = d.remote
+		for _, f := range d.forwarded {
+			req.Header.Add(forwardedForHeader, f)
+		}
+		if ip := r.clientIP(req).String(); ip != d.expected {
+			t.Errorf("Client IP from %s with %v: want %s, got %s", d.remote, d.forwarded, d.expected, ip)
+		}
+	}
+}
+
+func TestParseAccessRules(t *testing.T) {
+	cidrs, err := parseAccessRules([]string{"verbose=10.0.0.0/8, 192.0.2.1", "verbose=2001:db8::/32", "admin=::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cidrs[verboseRouteGroup]) != 3 || len(cidrs[adminRouteGroup]) != 1 {
+		t.Errorf("CIDRs: got %v", cidrs)
+	}
+	if cidrs[adminRouteGroup][0].String() != "::1/128" {
+		t.Errorf("CIDR of ::1: got %s", cidrs[adminRouteGroup][0])
+	}
+	for rule, expected := range map[string]string{
+		"verify":             `invalid access rule "verify": no CIDRs`,
+		"ui=10.0.0.0/8":      `invalid access rule "ui=10.0.0.0/8": unsupported group: ui`,
+		"verify=10.0.0.0/33": `invalid access rule "verify=10.0.0.0/33": unsupported CIDR: 10.0.0.0/33`,
+		"verify=localhost":   `invalid access rule "verify=localhost": unsupported CIDR: localhost`,
+	} {
+		if _, err := parseAccessRules([]string{rule}); err == nil || err.Error() != expected {
+			t.Errorf("Rule %s: want %q, got %v", rule, expected, err)
+		}
+	}
+}
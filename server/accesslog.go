@@ -0,0 +1,84 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/sirupsen/logrus"
+	"time"
+)
+
+type accessLogKey struct{}
+
+// accessLog collects the fields of the access log which are only known to the handlers.
+type accessLog struct {
+	verdict  checks.ResultStatus
+	cacheHit *bool
+}
+
+func withAccessLog(ctx context.Context, l *accessLog) context.Context {
+	return context.WithValue(ctx, accessLogKey{}, l)
+}
+
+// accessLogFrom gets the access log of the request, which is nil outside of requests.
+func accessLogFrom(ctx context.Context) *accessLog {
+	l, _ := ctx.Value(accessLogKey{}).(*accessLog)
+	return l
+}
+
+// accessLogMiddleware logs each request once it has been handled.
+func (r *server) accessLogMiddleware(context *gin.Context) {
+	start := time.Now()
+	l := &accessLog{}
+	context.Request = context.Request.WithContext(withAccessLog(context.Request.Context(), l))
+	context.Next()
+	fields := logrus.Fields{
+		"route":       context.FullPath(),
+		"method":      context.Request.Method,
+		"path":        context.Request.URL.Path,
+		"status":      context.Writer.Status(),
+		"size":        context.Writer.Size(),
+		"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if ip := r.clientIP(context.Request); ip != nil {
+		fields["client_ip"] = ip.String()
+	}
+	if l.verdict != "" {
+		fields["verdict"] = l.verdict
+	}
+	if l.cacheHit != nil {
+		fields["cache_hit"] = *l.cacheHit
+	}
+	if err := context.Errors.ByType(gin.ErrorTypePrivate).Last(); err != nil {
+		fields["error"] = err.Error()
+	}
+	r.logger.WithFields(fields).Info("Handled request")
+}
+
+// recordVerdict records the verdict of the request in its access log.
+func recordVerdict(context *gin.Context, v Verdict) {
+	if l := accessLogFrom(context.Request.Context()); l != nil {
+		l.verdict = v.Result.Status
+	}
+}
+
+// recordCacheHit records whether the checks were cached in the access log of the request.
+func recordCacheHit(ctx context.Context, hit bool) {
+	if l := accessLogFrom(ctx); l != nil {
+		l.cacheHit = &hit
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	r := newServer(config.DefaultServerConfig())
+	r.logger = logger
+	r.createJsonAPI()
+	r.createCache()
+	r.createTracker()
+	if err := r.createTracing(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.createClient()
+	r.cache().Set(fmt.Sprintf(consulChecksUrl, r.config().ConsulAddress), &map[string]*checks.Check{
+		"check1": {CheckID: "check1", Name: "check 1", Status: "critical", ServiceID: "web", ServiceName: "web"},
+	})
+	router := r.createRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/verify/service/id/web", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Failed to parse %s: %v", out.String(), err)
+	}
+	for k, v := range map[string]interface{}{
+		"msg":       "Handled request",
+		"route":     "/verify/service/id/:service",
+		"method":    "GET",
+		"path":      "/verify/service/id/web",
+		"status":    float64(r.config().ErrorStatusCode),
+		"verdict":   string(checks.Failed),
+		"cache_hit": true,
+		"client_ip": "192.0.2.1",
+	} {
+		if entry[k] != v {
+			t.Errorf("%s: want %v, got %v", k, v, entry[k])
+		}
+	}
+	if _, ok := entry["duration_ms"].(float64); !ok {
+		t.Errorf("duration_ms: want a number, got %v", entry["duration_ms"])
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces credentials in the config dump, like url.URL.Redacted does.
+const redactedValue = "xxxxx"
+
+// pprofParamKey is the path parameter of the pprof profile.
+const pprofParamKey = "profile"
+
+// adminListenerKey is the context key of requests from the admin listener.
+type adminListenerKey struct{}
+
+// createAdminServer creates the server of the admin listener, when it is enabled, which
+// shares the router of the public listener.
+func (r *server) createAdminServer(router *gin.Engine) {
+	if r.config().AdminListenAddress == "" {
+		return
+	}
+	r.adminServer = &http.Server{
+		Addr:         r.config().AdminListenAddress,
+		Handler:      router,
+		ReadTimeout:  r.config().ReadTimeout,
+		WriteTimeout: r.config().WriteTimeout,
+		ConnContext:  withConn,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), adminListenerKey{}, true)
+		},
+	}
+}
+
+// isAdminRoute gets whether the path is an operational route, which is only served by the
+// admin listener, when it is enabled.
+func isAdminRoute(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	switch path {
+	case aboutRoute, metricsRoute, historyRoute, configRoute, cacheFlushRoute:
+		return true
+	}
+	return path == pprofRoute || strings.HasPrefix(path, pprofRoute+"/")
+}
+
+// isServedBy gets whether the path is served by the admin listener, when admin, or otherwise by
+// the public listener, when the admin listener is enabled.  Both serve the OpenAPI document.
+func isServedBy(path string, admin bool) bool {
+	return admin == isAdminRoute(path) || strings.TrimSuffix(path, "/") == openAPIRoute
+}
+
+// listenerMiddleware responds with 404 Not Found to requests for operational routes from the
+// public listener, and for any other route from the admin listener.
+func (r *server) listenerMiddleware(context *gin.Context) {
+	admin, _ := context.Request.Context().Value(adminListenerKey{}).(bool)
+	if !isServedBy(context.Request.URL.Path, admin) {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	context.Next()
+}
+
+// handleAdmin registers the routes which are only served by the admin listener.
+func (r *server) handleAdmin(router *gin.Engine) {
+	r.handle(router, configRoute, r.configDump)
+	router.POST(cacheFlushRoute, r.flushCache)
+	router.GET(pprofRoute+"/*"+pprofParamKey, r.pprof)
+	router.POST(pprofRoute+"/*"+pprofParamKey, r.pprof)
+}
+
+func (r *server) configDump(context *gin.Context) {
+	r.json(context, r.config().SuccessStatusCode, redactedConfig(*r.config()))
+}
+
+func (r *server) flushCache(context *gin.Context) {
+	r.cache().Flush()
+	r.logger.Info("Flushed the Consul cache")
+	r.respond(context, Verdict{StatusCode: r.config().SuccessStatusCode,
+		Result: checks.Result{Status: checks.Ok, Detail: "Flushed the Consul cache"}})
+}
+
+func (r *server) pprof(context *gin.Context) {
+	switch context.Param(pprofParamKey) {
+	case "/cmdline":
+		pprof.Cmdline(context.Writer, context.Request)
+	case "/profile":
+		pprof.Profile(context.Writer, context.Request)
+	case "/symbol":
+		pprof.Symbol(context.Writer, context.Request)
+	case "/trace":
+		pprof.Trace(context.Writer, context.Request)
+	default:
+		pprof.Index(context.Writer, context.Request)
+	}
+}
+
+// redactedConfig gets a copy of the configuration without its credentials, which are bearer
+// tokens, webhook headers, and all but the scheme and host of webhook and Alertmanager URLs.
+func redactedConfig(c config.ServerConfig) config.ServerConfig {
+	rules := make([]string, len(c.AuthConfig.Rules))
+	for i, rule := range c.AuthConfig.Rules {
+		if j := strings.Index(rule, "="+bearerAuthMethod+":"); j >= 0 {
+			rule = rule[:j] + "=" + bearerAuthMethod + ":" + redactedValue
+		}
+		rules[i] = rule
+	}
+	c.AuthConfig.Rules = rules
+	webhooks := make([]config.WebhookConfig, len(c.Webhooks))
+	for i, w := range c.Webhooks {
+		headers := make(map[string]string, len(w.Headers))
+		for k := range w.Headers {
+			headers[k] = redactedValue
+		}
+		w.Headers = headers
+		w.URL = redactedURL(w.URL)
+		webhooks[i] = w
+	}
+	c.Webhooks = webhooks
+	c.AlertmanagerConfig.URL = redactedURL(c.AlertmanagerConfig.URL)
+	return c
+}
+
+// redactedURL gets the scheme and host of the URL, since its user info, path and query, like those of
+// Slack webhooks, may contain credentials.  A redacted path or query is replaced with redactedValue.
+func redactedURL(s string) string {
+	if s == "" {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	redacted := url.URL{Scheme: u.Scheme, Host: u.Host}
+	if strings.Trim(u.EscapedPath(), "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+		redacted.Path = "/" + redactedValue
+	}
+	return redacted.String()
+}
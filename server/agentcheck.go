@@ -0,0 +1,157 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/kadaan/consulate/checks"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	agentCheckMaxLineSize         = 1024
+	agentCheckUp                  = "up ready 100%"
+	agentCheckWarning             = "up ready %d%%"
+	agentCheckDown                = "down"
+	agentCheckMaintenance         = "maint"
+	agentCheckFail                = "fail"
+	nodeMaintenanceCheckID        = "_node_maintenance"
+	serviceMaintenanceCheckPrefix = "_service_maintenance:"
+)
+
+// agentCheckListener answers HAProxy agent checks with the status of its selector, or of the
+// selector sent by the agent check as the first line when it has none.  The selector is parsed
+// for each agent check, so reloaded profiles are used.
+type agentCheckListener struct {
+	r        *server
+	address  string
+	name     string
+	listener net.Listener
+	done     chan struct{}
+}
+
+// startAgentChecks starts a listener for each configured HAProxy agent check listener.
+func (r *server) startAgentChecks() error {
+	c := r.config().AgentCheckConfig
+	if c.WarningWeight < 0 || c.WarningWeight > 100 {
+		return errors.Errorf("invalid agent check warning weight: %d", c.WarningWeight)
+	}
+	for _, spec := range c.Listeners {
+		l := &agentCheckListener{r: r, address: spec, done: make(chan struct{})}
+		if i := strings.Index(spec, "="); i >= 0 {
+			if _, err := r.parseNamedSelector(spec[i+1:]); err != nil {
+				r.stopAgentChecks()
+				return errors.Wrapf(err, "invalid agent check listener %q", spec)
+			}
+			l.address = spec[:i]
+			l.name = spec[i+1:]
+		}
+		listener, err := net.Listen("tcp", l.address)
+		if err != nil {
+			r.stopAgentChecks()
+			return errors.Wrapf(err, "invalid agent check listener %q", spec)
+		}
+		l.listener = listener
+		r.agentChecks = append(r.agentChecks, l)
+		go l.serve()
+	}
+	return nil
+}
+
+func (r *server) stopAgentChecks() {
+	for _, l := range r.agentChecks {
+		close(l.done)
+		l.listener.Close()
+	}
+	r.agentChecks = nil
+}
+
+func (l *agentCheckListener) serve() {
+	l.r.logger.WithField("address", l.listener.Addr().String()).Info("Started Consulate agent check listener")
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			select {
+			case <-l.done:
+			default:
+				l.r.logger.WithFields(logrus.Fields{"address": l.address, "error": err}).Error("Failed to accept agent check")
+			}
+			return
+		}
+		go l.handle(conn)
+	}
+}
+
+// handle replies to an agent check with a single line, after reading the selector from the
+// first line when the listener has none.
+func (l *agentCheckListener) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(l.r.config().ReadTimeout))
+	name := l.name
+	if name == "" {
+		line, err := bufio.NewReaderSize(conn, agentCheckMaxLineSize).ReadSlice('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			l.r.logger.WithFields(logrus.Fields{"address": l.address, "error": err}).Warn("Failed to read agent check selector")
+			return
+		}
+		name = strings.TrimSpace(string(line))
+	}
+	sel, err := l.r.parseNamedSelector(name)
+	if err != nil {
+		l.r.logger.WithFields(logrus.Fields{"address": l.address, "selector": name, "error": err}).Warn("Invalid agent check selector")
+		fmt.Fprintln(conn, agentCheckFail)
+		return
+	}
+	allChecks, code, err := l.r.getChecks(context.Background())
+	reply := l.r.agentCheckReply(&snapshot{allChecks: allChecks, code: code, err: err}, sel)
+	l.r.logger.WithFields(logrus.Fields{"address": l.address, "selector": name, "reply": reply}).Debug("Answered agent check")
+	if _, err := fmt.Fprintln(conn, reply); err != nil {
+		l.r.logger.WithFields(logrus.Fields{"address": l.address, "error": err}).Warn("Failed to answer agent check")
+	}
+}
+
+// agentCheckReply gets the agent check reply for the selector: maint when the node or a
+// matching service is in maintenance, down when a check is failing or nothing matches, a
+// reduced weight when a check is warning, and otherwise up at full weight.
+func (r *server) agentCheckReply(s *snapshot, sel *selector) string {
+	if s.err != nil {
+		return agentCheckDown
+	}
+	for _, c := range *s.allChecks {
+		if c.CheckID == nodeMaintenanceCheckID {
+			return agentCheckMaintenance
+		}
+		if sel.matcher.match(c) && (strings.HasPrefix(c.CheckID, serviceMaintenanceCheckPrefix) || c.Status == checks.HealthMaintenance.String()) {
+			return agentCheckMaintenance
+		}
+	}
+	v := r.evaluate(s.allChecks, sel.matcher, sel.status, false)
+	switch {
+	case v.Counts[checks.StatusFailing] > 0:
+		return agentCheckDown
+	case v.Counts[checks.StatusWarning] > 0:
+		return fmt.Sprintf(agentCheckWarning, r.config().AgentCheckConfig.WarningWeight)
+	case v.Counts[checks.StatusPassing] > 0:
+		return agentCheckUp
+	}
+	return agentCheckDown
+}
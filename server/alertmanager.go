@@ -0,0 +1,252 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	alertmanagerAlertsPath = "/api/v2/alerts"
+	alertName              = "ConsulCheck"
+
+	// alertValidityIntervals is the number of resend intervals a firing alert is valid for, so
+	// that alerts resolve in Alertmanager if Consulate stops sending them.
+	alertValidityIntervals = 3
+)
+
+// alert is an Alertmanager postable alert.
+type alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// alertmanager sends an alert to Alertmanager for each failing or warning check in its
+// profiles.  Firing alerts are resent at the resend interval, and an alert is resolved when
+// its check recovers.
+type alertmanager struct {
+	r        *server
+	url      string
+	client   *http.Client
+	interval time.Duration
+	profiles []string
+	mu       sync.Mutex
+	firing   map[string]*alert
+	resolved map[string]*alert
+	wake     chan struct{}
+}
+
+func (r *server) createAlertmanager() error {
+	c := r.config().AlertmanagerConfig
+	if c.URL == "" {
+		return nil
+	}
+	if c.ResendInterval <= 0 {
+		return errors.Errorf("invalid alertmanager: unsupported resend interval: %v", c.ResendInterval)
+	}
+	if _, err := r.namedSelectors(nil, c.Profiles); err != nil {
+		return errors.Wrap(err, "invalid alertmanager")
+	}
+	a := &alertmanager{
+		r:        r,
+		url:      strings.TrimSuffix(c.URL, "/") + alertmanagerAlertsPath,
+		client:   createNotifierClient(&r.config().ClientConfig),
+		interval: c.ResendInterval,
+		profiles: c.Profiles,
+		firing:   make(map[string]*alert),
+		resolved: make(map[string]*alert),
+		wake:     make(chan struct{}, 1),
+	}
+	r.notifiers = append(r.notifiers, a)
+	return nil
+}
+
+// notify fires an alert for each failing or warning check, and resolves the alerts which are
+// no longer firing.  The alerts are left alone while Consul is unavailable.  The profiles are
+// looked up for each snapshot, so reloaded profiles are used.
+func (a *alertmanager) notify(s *snapshot) {
+	if s.err != nil {
+		return
+	}
+	selectors, err := a.r.namedSelectors(nil, a.profiles)
+	if err != nil {
+		a.r.logger.WithFields(logrus.Fields{"url": a.url, "error": err}).Error("Invalid Alertmanager profiles")
+		return
+	}
+	now := time.Now()
+	current := make(map[string]*alert)
+	for _, name := range selectorNames(selectors) {
+		v := a.r.evaluateSnapshot(s, selectors[name])
+		for id, status := range v.Statuses {
+			if status == checks.StatusPassing {
+				continue
+			}
+			if check, ok := v.Result.Checks[id]; ok {
+				al := newAlert(name, check, status)
+				current[alertKey(al.Labels)] = al
+			}
+		}
+	}
+	a.mu.Lock()
+	for key, al := range a.firing {
+		if _, ok := current[key]; !ok {
+			al.EndsAt = now
+			a.resolved[key] = al
+			delete(a.firing, key)
+		}
+	}
+	changed := false
+	for key, al := range current {
+		if existing, ok := a.firing[key]; ok {
+			existing.Annotations = al.Annotations
+			continue
+		}
+		al.StartsAt = now
+		a.firing[key] = al
+		delete(a.resolved, key)
+		changed = true
+	}
+	changed = changed || len(a.resolved) > 0
+	a.mu.Unlock()
+	if changed {
+		select {
+		case a.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// newAlert creates an alert, labelled by the fields of the check, for a check in a profile.
+func newAlert(profile string, check *checks.Check, status checks.Status) *alert {
+	severity := "critical"
+	if status == checks.StatusWarning {
+		severity = "warning"
+	}
+	al := &alert{
+		Labels: map[string]string{
+			"alertname": alertName,
+			"severity":  severity,
+			"profile":   strings.TrimPrefix(profile, profileCheckNamePrefix),
+		},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("Consul check %s is %s", check.Name, check.Status),
+		},
+	}
+	setLabel(al.Labels, "node", check.Node)
+	setLabel(al.Labels, "check", check.Name)
+	setLabel(al.Labels, "check_id", check.CheckID)
+	setLabel(al.Labels, "service", check.ServiceName)
+	setLabel(al.Labels, "service_id", check.ServiceID)
+	setLabel(al.Annotations, "description", check.Output)
+	setLabel(al.Annotations, "notes", check.Notes)
+	return al
+}
+
+// setLabel sets the label when the value is not empty.
+func setLabel(labels map[string]string, name string, value string) {
+	if value != "" {
+		labels[name] = value
+	}
+}
+
+// alertKey identifies an alert by its labels.
+func alertKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%q,", name, labels[name])
+	}
+	return b.String()
+}
+
+func (a *alertmanager) run(done <-chan struct{}) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.wake:
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		if err := a.send(); err != nil {
+			a.r.logger.WithFields(logrus.Fields{"url": a.url, "error": err}).Warn("Failed to send alerts to Alertmanager")
+		}
+	}
+}
+
+// send posts the firing alerts and the alerts which have not been sent since they resolved.
+func (a *alertmanager) send() error {
+	endsAt := time.Now().Add(alertValidityIntervals * a.interval)
+	a.mu.Lock()
+	alerts := make([]alert, 0, len(a.firing)+len(a.resolved))
+	for _, al := range a.firing {
+		al.EndsAt = endsAt
+		alerts = append(alerts, *al)
+	}
+	resolved := make(map[string]*alert, len(a.resolved))
+	for key, al := range a.resolved {
+		resolved[key] = al
+		alerts = append(alerts, *al)
+	}
+	a.mu.Unlock()
+	if len(alerts) == 0 {
+		return nil
+	}
+	if err := a.post(alerts); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	for key, al := range resolved {
+		if a.resolved[key] == al {
+			delete(a.resolved, key)
+		}
+	}
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *alertmanager) post(alerts []alert) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(alerts); err != nil {
+		return err
+	}
+	resp, err := a.client.Post(a.url, gin.MIMEJSON, &body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/config"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAlertmanagerClient(t *testing.T) {
+	c := config.DefaultServerConfig()
+	c.ClientConfig.QueryTimeout = 7 * time.Second
+	c.Profiles = map[string]string{"web": "/verify/checks"}
+	c.AlertmanagerConfig.URL = "http://alertmanager:9093"
+	r := newServer(c)
+	if err := r.createProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.createAlertmanager(); err != nil {
+		t.Fatal(err)
+	}
+	a := r.notifiers[0].(*alertmanager)
+	if _, ok := a.client.Transport.(*http.Transport); !ok {
+		t.Errorf("Transport: want *http.Transport, got %T", a.client.Transport)
+	}
+	if a.client.Timeout != c.ClientConfig.QueryTimeout {
+		t.Errorf("Timeout: want %v, got %v", c.ClientConfig.QueryTimeout, a.client.Timeout)
+	}
+}
+
+func TestInvalidAlertmanagerResendInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		c := config.DefaultServerConfig()
+		c.AlertmanagerConfig.URL = "http://alertmanager:9093"
+		c.AlertmanagerConfig.ResendInterval = interval
+		r := newServer(c)
+		if err := r.createProfiles(); err != nil {
+			t.Fatal(err)
+		}
+		err := r.createAlertmanager()
+		if expected := "invalid alertmanager: unsupported resend interval: " + interval.String(); err == nil || err.Error() != expected {
+			t.Errorf("Error: %v, want %q", err, expected)
+		}
+	}
+}
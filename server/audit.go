@@ -0,0 +1,397 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	fromQueryStringKey  = "from"
+	toQueryStringKey    = "to"
+	limitQueryStringKey = "limit"
+	auditMaxLineSize    = 16 * 1024 * 1024
+
+	// defaultHistoryLimit is the number of transitions returned by /history without a limit.
+	defaultHistoryLimit = 1000
+
+	// maxHistoryLimit is the maximum number of transitions returned by /history.
+	maxHistoryLimit = 10000
+)
+
+// Transition is a change in the status of a Consul check or of a profile, as recorded in the
+// audit log and returned by /history.  Transitions of checks have a CheckID, and transitions of
+// profiles have a Selector, with the detail of the result as the output.
+type Transition struct {
+	Time        time.Time
+	CheckID     string `json:",omitempty"`
+	Selector    string `json:",omitempty"`
+	ServiceID   string `json:",omitempty"`
+	ServiceName string `json:",omitempty"`
+	Node        string `json:",omitempty"`
+	OldStatus   string
+	NewStatus   string
+	Output      string `json:",omitempty"`
+}
+
+// auditLog appends a Transition to a file, as a line of JSON, whenever the status of a Consul
+// check or profile changes.  The file is rotated when it would grow beyond the maximum size, and
+// the rotated files are named <path>.1, for the newest, to <path>.<max backups>.
+type auditLog struct {
+	r      *server
+	config config.AuditConfig
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	checks map[string]*checks.Check
+	states map[string]checks.ResultStatus
+}
+
+func (r *server) createAudit() error {
+	c := r.config().AuditConfig
+	if c.Path == "" {
+		return nil
+	}
+	if c.MaxSize <= 0 {
+		return errors.Errorf("invalid audit log: unsupported max size: %d", c.MaxSize)
+	}
+	if c.MaxBackups < 0 {
+		return errors.Errorf("invalid audit log: unsupported max backups: %d", c.MaxBackups)
+	}
+	a := &auditLog{
+		r:      r,
+		config: c,
+		states: make(map[string]checks.ResultStatus),
+	}
+	if err := a.open(); err != nil {
+		return errors.Wrap(err, "invalid audit log")
+	}
+	r.audit = a
+	r.notifiers = append(r.notifiers, a)
+	return nil
+}
+
+func (a *auditLog) open() error {
+	file, err := os.OpenFile(a.config.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+// notify records the transitions since the previous snapshot.  The first snapshot only records
+// the status of each check and profile, and when Consul could not be queried, the checks are
+// left as they were, while the profiles fail.  The profiles are looked up for each snapshot, so
+// reloaded profiles are used.
+func (a *auditLog) notify(s *snapshot) {
+	now := time.Now().UTC()
+	var transitions []Transition
+	if s.err == nil {
+		if a.checks != nil {
+			transitions = append(transitions, checkTransitions(now, a.checks, *s.allChecks)...)
+		}
+		a.checks = *s.allChecks
+	}
+	selectors := make(map[string]*selector)
+	for name, sel := range a.r.profiles() {
+		selectors[profileCheckNamePrefix+name] = sel
+	}
+	forgetStates(a.states, selectors)
+	for _, name := range selectorNames(selectors) {
+		v := a.r.evaluateSnapshot(s, selectors[name])
+		old, seen := a.states[name]
+		a.states[name] = v.Result.Status
+		if !seen || old == v.Result.Status {
+			continue
+		}
+		transitions = append(transitions, Transition{Time: now, Selector: name, OldStatus: string(old),
+			NewStatus: string(v.Result.Status), Output: v.Result.Detail})
+	}
+	if len(transitions) > 0 {
+		a.write(transitions)
+	}
+}
+
+// checkTransitions gets a transition, ordered by CheckID, for each check whose status differs
+// between the old and new checks.  Checks which were added or removed have an empty old or new
+// status.
+func checkTransitions(now time.Time, old map[string]*checks.Check, current map[string]*checks.Check) []Transition {
+	var ids []string
+	for id := range current {
+		ids = append(ids, id)
+	}
+	for id := range old {
+		if _, ok := current[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	var transitions []Transition
+	for _, id := range ids {
+		o, n := old[id], current[id]
+		t := Transition{Time: now, CheckID: id}
+		described := n
+		if o != nil {
+			t.OldStatus = o.Status
+		}
+		if n != nil {
+			t.NewStatus = n.Status
+		} else {
+			described = o
+		}
+		if t.OldStatus == t.NewStatus {
+			continue
+		}
+		t.ServiceID = described.ServiceID
+		t.ServiceName = described.ServiceName
+		t.Node = described.Node
+		t.Output = described.Output
+		transitions = append(transitions, t)
+	}
+	return transitions
+}
+
+func (a *auditLog) write(transitions []Transition) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return
+	}
+	maxSize := int64(a.config.MaxSize) * 1024 * 1024
+	for _, t := range transitions {
+		b, err := json.Marshal(t)
+		if err != nil {
+			a.r.logger.WithError(err).Error("Failed to encode audit log transition")
+			continue
+		}
+		b = append(b, '\n')
+		if a.size > 0 && a.size+int64(len(b)) > maxSize {
+			if err := a.rotate(); err != nil {
+				a.r.logger.WithFields(logrus.Fields{"path": a.config.Path, "error": err}).Error("Failed to rotate audit log")
+				if a.file == nil {
+					return
+				}
+			}
+		}
+		n, err := a.file.Write(b)
+		a.size += int64(n)
+		if err != nil {
+			a.r.logger.WithFields(logrus.Fields{"path": a.config.Path, "error": err}).Error("Failed to write audit log")
+		}
+	}
+}
+
+// rotate renames the audit log to <path>.1, after shifting the existing backups and removing
+// the oldest, and then opens a new audit log.
+func (a *auditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	a.file = nil
+	if a.config.MaxBackups == 0 {
+		if err := os.Remove(a.config.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.Remove(a.backup(a.config.MaxBackups)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for i := a.config.MaxBackups - 1; i > 0; i-- {
+			if err := os.Rename(a.backup(i), a.backup(i+1)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		if err := os.Rename(a.config.Path, a.backup(1)); err != nil {
+			return err
+		}
+	}
+	return a.open()
+}
+
+func (a *auditLog) backup(i int) string {
+	return fmt.Sprintf("%s.%d", a.config.Path, i)
+}
+
+func (a *auditLog) run(done <-chan struct{}) {
+	<-done
+	a.close()
+}
+
+func (a *auditLog) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// read gets the latest limit transitions at or after from and before to, unless to is zero, from
+// the oldest backup to the audit log.  When services are specified, only the transitions of their
+// checks are included.  Lines which cannot be parsed, like one being written when Consulate
+// stopped, are skipped.  The files are read without holding mu, so writes are not blocked.
+func (a *auditLog) read(from time.Time, to time.Time, services []string, limit int) ([]Transition, error) {
+	readers, files, err := a.openFiles()
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+	transitions := []Transition{}
+	for _, reader := range readers {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(nil, auditMaxLineSize)
+		for scanner.Scan() {
+			var t Transition
+			if json.Unmarshal(scanner.Bytes(), &t) != nil {
+				continue
+			}
+			if t.Time.Before(from) || (!to.IsZero() && !t.Time.Before(to)) || !matchesService(t, services) {
+				continue
+			}
+			if len(transitions) == limit {
+				transitions = transitions[1:]
+			}
+			transitions = append(transitions, t)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return transitions, nil
+}
+
+// openFiles opens the backups, oldest first, and then the audit log, holding mu so that they are
+// not rotated in between.  The audit log is limited to the transitions which have been written,
+// since the open files can still be read after they are rotated.
+func (a *auditLog) openFiles() ([]io.Reader, []*os.File, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	paths := []string{a.config.Path}
+	for i := 1; i <= a.config.MaxBackups; i++ {
+		paths = append([]string{a.backup(i)}, paths...)
+	}
+	var readers []io.Reader
+	var files []*os.File
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, files, err
+		}
+		files = append(files, file)
+		if path == a.config.Path && a.file != nil {
+			readers = append(readers, io.LimitReader(file, a.size))
+		} else {
+			readers = append(readers, file)
+		}
+	}
+	return readers, files, nil
+}
+
+func matchesService(t Transition, services []string) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for _, s := range services {
+		if t.CheckID != "" && t.ServiceName == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *server) history(context *gin.Context) {
+	if r.audit == nil {
+		r.respond(context, Verdict{StatusCode: http.StatusNotFound,
+			Result: checks.Result{Status: checks.Failed, Detail: "Audit log is not enabled"}})
+		return
+	}
+	from, ok := r.parseTime(context, fromQueryStringKey, time.Time{})
+	if !ok {
+		return
+	}
+	to, ok := r.parseTime(context, toQueryStringKey, time.Time{})
+	if !ok {
+		return
+	}
+	limit, ok := r.parseLimit(context)
+	if !ok {
+		return
+	}
+	transitions, err := r.audit.read(from, to, context.QueryArray(serviceQueryStringKey), limit)
+	if err != nil {
+		r.logger.WithFields(logrus.Fields{"path": r.config().AuditConfig.Path, "error": err}).Error("Failed to read audit log")
+		r.respond(context, Verdict{StatusCode: http.StatusInternalServerError,
+			Result: checks.Result{Status: checks.Failed, Detail: "Failed to read audit log"}})
+		return
+	}
+	r.json(context, r.config().SuccessStatusCode, transitions)
+}
+
+// parseTime gets the RFC 3339 time in the specified query string parameter, or the default
+// when it is not specified, responding with a bad request when it cannot be parsed.
+func (r *server) parseTime(context *gin.Context, key string, defaultTime time.Time) (time.Time, bool) {
+	value, ok := context.GetQuery(key)
+	if !ok {
+		return defaultTime, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		r.respondBadRequest(context, fmt.Sprintf("Unsupported %s: %v", key, value))
+		return t, false
+	}
+	return t, true
+}
+
+// parseLimit gets the limit query string parameter, or the default when it is not specified,
+// responding with a bad request when it is not between 1 and the max.
+func (r *server) parseLimit(context *gin.Context) (int, bool) {
+	value, ok := context.GetQuery(limitQueryStringKey)
+	if !ok {
+		return defaultHistoryLimit, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		r.respondBadRequest(context, fmt.Sprintf("Unsupported %s: %v", limitQueryStringKey, value))
+		return limit, false
+	}
+	return limit, true
+}
@@ -0,0 +1,148 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	a := &auditLog{r: &server{logger: logrus.New()}, config: config.AuditConfig{Path: path, MaxSize: 1, MaxBackups: 1}}
+	if err := a.open(); err != nil {
+		t.Fatal(err)
+	}
+	output := strings.Repeat("x", 400*1024)
+	start := time.Now().UTC()
+	for i, id := range []string{"check1", "check2", "check3", "check4"} {
+		a.write([]Transition{{Time: start.Add(time.Duration(i) * time.Second), CheckID: id, OldStatus: "passing", NewStatus: "critical", Output: output}})
+	}
+	done := make(chan struct{})
+	close(done)
+	a.run(done)
+
+	if _, err := os.Stat(a.backup(1)); err != nil {
+		t.Errorf("Backup was not created: %v", err)
+	}
+	if _, err := os.Stat(a.backup(2)); !os.IsNotExist(err) {
+		t.Errorf("Backup beyond the maximum was kept: %v", err)
+	}
+	transitions, err := a.read(time.Time{}, time.Time{}, nil, defaultHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, tr := range transitions {
+		ids = append(ids, tr.CheckID)
+	}
+	if strings.Join(ids, ",") != "check1,check2,check3,check4" {
+		t.Errorf("Transitions: want check1,check2,check3,check4, got %v", ids)
+	}
+	transitions, err = a.read(start.Add(time.Second), start.Add(3*time.Second), nil, defaultHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transitions) != 2 || transitions[0].CheckID != "check2" || transitions[1].CheckID != "check3" {
+		t.Errorf("Transitions between: got %+v", transitions)
+	}
+	transitions, err = a.read(time.Time{}, time.Time{}, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transitions) != 3 || transitions[0].CheckID != "check2" || transitions[2].CheckID != "check4" {
+		t.Errorf("Latest transitions: got %+v", transitions)
+	}
+}
+
+func TestAuditLogReadDuringRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	a := &auditLog{r: &server{logger: logrus.New()}, config: config.AuditConfig{Path: path, MaxSize: 1, MaxBackups: 1}}
+	if err := a.open(); err != nil {
+		t.Fatal(err)
+	}
+	output := strings.Repeat("x", 400*1024)
+	start := time.Now().UTC()
+	a.write([]Transition{{Time: start, CheckID: "check1", OldStatus: "passing", NewStatus: "critical", Output: output}})
+	readers, files, err := a.openFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for i, id := range []string{"check2", "check3", "check4"} {
+		a.write([]Transition{{Time: start.Add(time.Duration(i+1) * time.Second), CheckID: id, OldStatus: "passing", NewStatus: "critical", Output: output}})
+	}
+	if len(readers) != 1 {
+		t.Fatalf("Readers: want 1, got %d", len(readers))
+	}
+	b, err := ioutil.ReadAll(readers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 1 || !strings.Contains(string(b), `"check1"`) {
+		t.Errorf("Audit log opened before rotation: want check1, got %d lines", lines)
+	}
+}
+
+func TestCheckTransitions(t *testing.T) {
+	now := time.Now()
+	old := map[string]*checks.Check{
+		"a": {CheckID: "a", Status: "passing", ServiceName: "web"},
+		"b": {CheckID: "b", Status: "passing", Output: "removed"},
+		"c": {CheckID: "c", Status: "passing"},
+	}
+	current := map[string]*checks.Check{
+		"a": {CheckID: "a", Status: "critical", ServiceName: "web", Node: "node1", Output: "down"},
+		"c": {CheckID: "c", Status: "passing", Output: "changed"},
+		"d": {CheckID: "d", Status: "warning"},
+	}
+	transitions := checkTransitions(now, old, current)
+	expected := []Transition{
+		{Time: now, CheckID: "a", ServiceName: "web", Node: "node1", OldStatus: "passing", NewStatus: "critical", Output: "down"},
+		{Time: now, CheckID: "b", OldStatus: "passing", NewStatus: "", Output: "removed"},
+		{Time: now, CheckID: "d", OldStatus: "", NewStatus: "warning"},
+	}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Transitions: want %+v, got %+v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Transition %d: want %+v, got %+v", i, expected[i], transitions[i])
+		}
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/statsd"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	bearerAuthMethod    = "bearer"
+	tokenFileAuthMethod = "token-file"
+	htpasswdAuthMethod  = "htpasswd"
+	authorizationHeader = "Authorization"
+	authenticateHeader  = "WWW-Authenticate"
+	identityKey         = "identity"
+	authFailedKey       = "auth_failed"
+	bearerScheme        = "Bearer"
+	basicScheme         = "Basic"
+	authRealm           = `realm="consulate"`
+	missingAuthReason   = "missing"
+	invalidAuthReason   = "invalid"
+)
+
+// authenticator authenticates requests with one method of authentication.
+type authenticator interface {
+	// authenticate returns the identity of the request, and whether it has valid credentials.
+	authenticate(req *http.Request) (string, bool)
+
+	// scheme gets the HTTP authentication scheme, like Bearer.
+	scheme() string
+}
+
+// authFile loads the credentials from a file, and reloads them whenever it changes.
+type authFile interface {
+	authenticator
+	path() string
+	load(b []byte) error
+}
+
+// createAuth creates the authenticators of the route groups.
+func (r *server) createAuth() error {
+	r.authenticators = make(map[string][]authenticator)
+	for _, rule := range r.config().AuthConfig.Rules {
+		group, a, err := parseAuthRule(rule)
+		if err != nil {
+			return errors.Wrapf(err, "invalid auth rule %q", rule)
+		}
+		if f, ok := a.(authFile); ok {
+			if err := r.loadAuthFile(f); err != nil {
+				return errors.Wrapf(err, "invalid auth rule %q", rule)
+			}
+		}
+		r.authenticators[group] = append(r.authenticators[group], a)
+	}
+	if len(r.authenticators) == 0 {
+		return nil
+	}
+	if r.config().AuthConfig.ReloadInterval <= 0 {
+		return errors.Errorf("invalid auth: unsupported reload interval: %v", r.config().AuthConfig.ReloadInterval)
+	}
+	r.authFailures = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "consulate",
+		Name:      "auth_failures_total",
+		Help:      "Total number of HTTP requests which could not be authenticated.",
+	}, []string{"group", "reason"})).(*prometheus.CounterVec)
+	r.authDone = make(chan struct{})
+	go r.watchAuthFiles(r.config().AuthConfig.ReloadInterval, r.authDone)
+	return nil
+}
+
+func parseAuthRule(rule string) (string, authenticator, error) {
+	group, method, err := splitGroupRule(rule)
+	if err != nil {
+		return "", nil, err
+	}
+	if method == "" {
+		return "", nil, errors.New("no method")
+	}
+	value := ""
+	if j := strings.Index(method, ":"); j >= 0 {
+		method, value = method[:j], method[j+1:]
+	}
+	if value == "" {
+		return "", nil, errors.Errorf("no value for method: %s", method)
+	}
+	switch method {
+	case bearerAuthMethod:
+		return group, &bearerTokens{tokens: [][]byte{[]byte(value)}}, nil
+	case tokenFileAuthMethod:
+		return group, &tokenFile{file: value}, nil
+	case htpasswdAuthMethod:
+		return group, &htpasswd{file: value}, nil
+	}
+	return "", nil, errors.Errorf("unsupported method: %s", method)
+}
+
+func (r *server) stopAuth() {
+	if r.authDone != nil {
+		close(r.authDone)
+		r.authDone = nil
+	}
+}
+
+// loadAuthFile reads the file and loads its credentials.
+func (r *server) loadAuthFile(f authFile) error {
+	b, err := ioutil.ReadFile(f.path())
+	if err != nil {
+		return err
+	}
+	return f.load(b)
+}
+
+// watchAuthFiles reloads the token and htpasswd files whenever they change.  When a file cannot
+// be loaded, the previous credentials are used until it changes again.
+func (r *server) watchAuthFiles(interval time.Duration, done <-chan struct{}) {
+	versions := make(map[authFile]string)
+	for _, authenticators := range r.authenticators {
+		for _, a := range authenticators {
+			if f, ok := a.(authFile); ok {
+				versions[f], _ = fileVersion(f.path())
+			}
+		}
+	}
+	if len(versions) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+		for f, previous := range versions {
+			version, err := fileVersion(f.path())
+			if err == nil && version == previous {
+				continue
+			}
+			versions[f] = version
+			if err == nil {
+				err = r.loadAuthFile(f)
+			}
+			if err != nil {
+				r.logger.WithFields(logrus.Fields{"path": f.path(), "error": err}).Error("Failed to reload auth file")
+				continue
+			}
+			r.logger.WithField("path", f.path()).Info("Reloaded auth file")
+		}
+	}
+}
+
+// authMiddleware responds with the unauthorized status code when the route group of the request
+// has authenticators, and none of them authenticate it.
+func (r *server) authMiddleware(context *gin.Context) {
+	group := routeGroup(context.Request)
+	authenticators := r.authenticators[group]
+	if len(authenticators) == 0 {
+		context.Next()
+		return
+	}
+	schemes := make(map[string]bool)
+	for _, a := range authenticators {
+		if identity, ok := a.authenticate(context.Request); ok {
+			context.Set(identityKey, identity)
+			context.Next()
+			return
+		}
+		if !schemes[a.scheme()] {
+			schemes[a.scheme()] = true
+			context.Writer.Header().Add(authenticateHeader, a.scheme()+" "+authRealm)
+		}
+	}
+	reason := invalidAuthReason
+	if context.GetHeader(authorizationHeader) == "" {
+		reason = missingAuthReason
+	}
+	context.Set(authFailedKey, true)
+	r.authFailures.WithLabelValues(group, reason).Inc()
+	r.statsd.Count("auth_failures", 1, statsd.Tag("group", group), statsd.Tag("reason", reason))
+	r.respond(context, Verdict{StatusCode: r.config().UnauthorizedStatusCode,
+		Result: checks.Result{Status: checks.Failed, Detail: "Unauthorized"}})
+}
+
+// bearerTokens authenticates requests with any of its tokens as a bearer token.
+type bearerTokens struct {
+	mu     sync.RWMutex
+	tokens [][]byte
+}
+
+// authenticate identifies requests by a hash of the token, so the token is not exposed.
+func (b *bearerTokens) authenticate(req *http.Request) (string, bool) {
+	header := req.Header.Get(authorizationHeader)
+	if !strings.HasPrefix(header, bearerScheme+" ") {
+		return "", false
+	}
+	token := []byte(strings.TrimPrefix(header, bearerScheme+" "))
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, t := range b.tokens {
+		if subtle.ConstantTimeCompare(token, t) == 1 {
+			sum := sha256.Sum256(token)
+			return "bearer:" + hex.EncodeToString(sum[:8]), true
+		}
+	}
+	return "", false
+}
+
+func (b *bearerTokens) scheme() string {
+	return bearerScheme
+}
+
+// tokenFile authenticates requests with any of the bearer tokens in a file, which has a token
+// on each line, ignoring empty lines and comments starting with #.
+type tokenFile struct {
+	bearerTokens
+	file string
+}
+
+func (t *tokenFile) path() string {
+	return t.file
+}
+
+func (t *tokenFile) load(b []byte) error {
+	var tokens [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			tokens = append(tokens, []byte(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.tokens = tokens
+	t.mu.Unlock()
+	return nil
+}
+
+// htpasswd authenticates requests with basic auth against an htpasswd file, whose passwords
+// are hashed with bcrypt or SHA-1.
+type htpasswd struct {
+	mu    sync.RWMutex
+	file  string
+	users map[string]string
+}
+
+func (h *htpasswd) path() string {
+	return h.file
+}
+
+func (h *htpasswd) load(b []byte) error {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			return errors.Errorf("invalid htpasswd line: %s", line)
+		}
+		user, hash := line[:i], line[i+1:]
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return errors.Errorf("unsupported htpasswd hash for user: %s", user)
+		}
+		users[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.users = users
+	h.mu.Unlock()
+	return nil
+}
+
+// authenticate identifies requests by the user.
+func (h *htpasswd) authenticate(req *http.Request) (string, bool) {
+	user, password, ok := req.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	h.mu.RLock()
+	hash, ok := h.users[user]
+	h.mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(password))
+		ok = subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(hash, "{SHA}")), []byte(base64.StdEncoding.EncodeToString(sum[:]))) == 1
+	} else {
+		ok = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	}
+	return "basic:" + user, ok
+}
+
+func (h *htpasswd) scheme() string {
+	return basicScheme
+}
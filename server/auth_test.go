@@ -0,0 +1,91 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAuthRule(t *testing.T) {
+	for rule, expected := range map[string]string{
+		"verify":              "no method",
+		"public=bearer:token": "unsupported group: public",
+		"verify=bearer":       "no value for method: bearer",
+		"verify=oauth:token":  "unsupported method: oauth",
+	} {
+		if _, _, err := parseAuthRule(rule); err == nil || err.Error() != expected {
+			t.Errorf("Rule %s: want %q, got %v", rule, expected, err)
+		}
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	f := &tokenFile{}
+	if err := f.load([]byte("# tokens\n\ntoken1\n  token2  \n")); err != nil {
+		t.Fatal(err)
+	}
+	for token, expected := range map[string]bool{
+		"Bearer token1":   true,
+		"Bearer token2":   true,
+		"Bearer # tokens": false,
+		"Bearer ":         false,
+		"token1":          false,
+	} {
+		req := httptest.NewRequest("GET", "/verify/checks", nil)
+		req.Header.Set(authorizationHeader, token)
+		if _, authenticated := f.authenticate(req); authenticated != expected {
+			t.Errorf("Authorization %q: want %v, got %v", token, expected, authenticated)
+		}
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &htpasswd{}
+	// The SHA-1 hash is of "password".
+	if err := h.load([]byte("alice:" + string(hash) + "\nbob:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []struct {
+		user     string
+		password string
+		expected bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "password", false},
+		{"bob", "password", true},
+		{"bob", "secret", false},
+		{"carol", "secret", false},
+	} {
+		req := httptest.NewRequest("GET", "/history", nil)
+		req.SetBasicAuth(d.user, d.password)
+		if _, authenticated := h.authenticate(req); authenticated != d.expected {
+			t.Errorf("User %s with %s: want %v, got %v", d.user, d.password, d.expected, authenticated)
+		}
+	}
+	req := httptest.NewRequest("GET", "/history", nil)
+	req.SetBasicAuth("alice", "secret")
+	if identity, _ := h.authenticate(req); identity != "basic:alice" {
+		t.Errorf("Identity: want basic:alice, got %s", identity)
+	}
+	if err := h.load([]byte("carol:$apr1$salt$hash\n")); err == nil || err.Error() != "unsupported htpasswd hash for user: carol" {
+		t.Errorf("Load of MD5 hash: got %v", err)
+	}
+}
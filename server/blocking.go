@@ -0,0 +1,153 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	indexHeader           = "X-Consulate-Index"
+	waitQueryStringKey    = "wait"
+	indexQueryStringKey   = "index"
+	maxWaitTimeoutPadding = time.Second
+)
+
+// connKey is the context key of the connection of requests, whose write deadline is extended
+// while blocking queries and event streams are held.
+type connKey struct{}
+
+func withConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, c)
+}
+
+// blockingQuery represents the wait and index query string parameters, which hold a request
+// until its result differs from the result identified by index.
+type blockingQuery struct {
+	index uint64
+	wait  time.Duration
+}
+
+// getBlockingQuery gets the blocking query of the request, which is nil if the index
+// query string parameter is not specified.
+func (r *server) getBlockingQuery(context *gin.Context) (*blockingQuery, bool) {
+	index, indexSpecified := context.GetQuery(indexQueryStringKey)
+	wait, waitSpecified := context.GetQuery(waitQueryStringKey)
+	q := &blockingQuery{wait: r.maxWait(context.Request)}
+	if waitSpecified {
+		parsedWait, err := time.ParseDuration(wait)
+		if err != nil || parsedWait < 0 {
+			r.respondBadRequest(context, fmt.Sprintf("Unsupported wait: %v", wait))
+			return nil, false
+		}
+		if parsedWait > q.wait {
+			r.respondBadRequest(context, fmt.Sprintf("Unsupported wait: %v exceeds the max wait of %v", wait, q.wait))
+			return nil, false
+		}
+		q.wait = parsedWait
+	}
+	if !indexSpecified {
+		return nil, true
+	}
+	parsedIndex, err := strconv.ParseUint(index, 10, 64)
+	if err != nil {
+		r.respondBadRequest(context, fmt.Sprintf("Unsupported index: %v", index))
+		return nil, false
+	}
+	q.index = parsedIndex
+	return q, true
+}
+
+// maxWait is the longest the request may be held.  The write deadline of HTTP/1 connections is
+// extended while the request is held, but HTTP/2 requests are also limited by the write timeout,
+// leaving time to write the response, since their write deadline cannot be extended.
+func (r *server) maxWait(req *http.Request) time.Duration {
+	max := r.config().MaxWait
+	if _, ok := req.Context().Value(connKey{}).(net.Conn); (ok && req.ProtoMajor == 1) || r.config().WriteTimeout <= 0 {
+		return max
+	}
+	limit := r.config().WriteTimeout - maxWaitTimeoutPadding
+	if limit < 0 {
+		limit = 0
+	}
+	if limit < max {
+		return limit
+	}
+	return max
+}
+
+// extendWriteDeadline extends the write deadline of the HTTP/1 connection of the request, so the
+// write timeout applies after the wait.  The write deadline of HTTP/2 streams cannot be extended.
+func (r *server) extendWriteDeadline(req *http.Request, wait time.Duration) {
+	conn, ok := req.Context().Value(connKey{}).(net.Conn)
+	if !ok || req.ProtoMajor != 1 || r.config().WriteTimeout <= 0 {
+		return
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(wait + r.config().WriteTimeout)); err != nil {
+		r.logger.WithError(err).Warn("Failed to extend the write deadline")
+	}
+}
+
+// block evaluates the latest snapshot until the verdict no longer has the index of the
+// blocking query, or the wait expires.
+func (r *server) block(context *gin.Context, q *blockingQuery, evaluate func(allChecks *map[string]*checks.Check) Verdict) (Verdict, bool) {
+	r.extendWriteDeadline(context.Request, q.wait)
+	timeout := time.NewTimer(q.wait)
+	defer timeout.Stop()
+	for {
+		snap, changed := r.watcher.latest()
+		var v Verdict
+		if snap.err != nil {
+			v = Verdict{StatusCode: snap.code, Result: checks.Result{Status: checks.Failed, Detail: snap.err.Error()}}
+		} else {
+			v = evaluate(snap.allChecks)
+		}
+		if resultIndex(v) != q.index {
+			return v, true
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return v, true
+		case <-r.watcher.stopped():
+			return v, true
+		case <-context.Request.Context().Done():
+			return v, false
+		}
+	}
+}
+
+// resultIndex identifies a verdict by its status code and result.
+func resultIndex(v Verdict) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:", v.StatusCode)
+	b, _ := json.Marshal(v.Result)
+	h.Write(b)
+	return h.Sum64()
+}
+
+func (r *server) respondBadRequest(context *gin.Context, detail string) {
+	r.respond(context, Verdict{StatusCode: r.config().BadRequestStatusCode,
+		Result: checks.Result{Status: checks.Failed, Detail: detail}})
+}
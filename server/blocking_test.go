@@ -0,0 +1,53 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/config"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxWait(t *testing.T) {
+	conn, _ := net.Pipe()
+	defer conn.Close()
+	for _, d := range []struct {
+		writeTimeout time.Duration
+		protoMajor   int
+		conn         bool
+		expected     time.Duration
+	}{
+		{10 * time.Second, 1, true, config.DefaultMaxWait},
+		{10 * time.Second, 1, false, 9 * time.Second},
+		{10 * time.Second, 2, true, 9 * time.Second},
+		{time.Hour, 2, true, config.DefaultMaxWait},
+		{500 * time.Millisecond, 2, true, 0},
+		{0, 2, true, config.DefaultMaxWait},
+	} {
+		c := config.DefaultServerConfig()
+		c.WriteTimeout = d.writeTimeout
+		r := newServer(c)
+		req := httptest.NewRequest("GET", "/verify/checks", nil)
+		req.ProtoMajor = d.protoMajor
+		if d.conn {
+			req = req.WithContext(withConn(req.Context(), conn))
+		}
+		if wait := r.maxWait(req); wait != d.expected {
+			t.Errorf("%+v: want %v, got %v", d, d.expected, wait)
+		}
+	}
+}
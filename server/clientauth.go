@@ -0,0 +1,157 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	verboseClientPolicy = "verbose"
+	anyClientName       = "*"
+)
+
+// clientPolicy requires a verified client certificate, with a common name or subject
+// alternative name in its names, to request the routes matching its pattern.  The pattern is a
+// path, a path prefix ending with /*, or verbose for requests with the verbose query string
+// parameter.  The name * allows any verified client certificate.
+type clientPolicy struct {
+	pattern string
+	names   map[string]bool
+}
+
+// createClientPolicies parses the client policies, which require a client CA file.
+func (r *server) createClientPolicies() error {
+	c := r.config().TLSConfig
+	for _, spec := range c.ClientPolicies {
+		if c.ClientCAFile == "" {
+			return errors.New("invalid TLS: client policies require a client CA file")
+		}
+		p, err := parseClientPolicy(spec)
+		if err != nil {
+			return errors.Wrapf(err, "invalid TLS client policy %q", spec)
+		}
+		r.clientPolicies = append(r.clientPolicies, p)
+	}
+	return nil
+}
+
+func parseClientPolicy(spec string) (*clientPolicy, error) {
+	i := strings.Index(spec, "=")
+	if i < 0 {
+		return nil, errors.New("no names")
+	}
+	p := &clientPolicy{pattern: spec[:i], names: make(map[string]bool)}
+	if p.pattern != verboseClientPolicy && !strings.HasPrefix(p.pattern, "/") {
+		return nil, errors.Errorf("unsupported pattern: %s", p.pattern)
+	}
+	for _, name := range strings.Split(spec[i+1:], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			p.names[name] = true
+		}
+	}
+	if len(p.names) == 0 {
+		return nil, errors.New("no names")
+	}
+	return p, nil
+}
+
+// configureClientCA verifies the client certificates which are presented against the client
+// CA file.  Client certificates are not required, so that routes without a policy stay open.
+func (r *server) configureClientCA(c *tls.Config) error {
+	if r.config().TLSConfig.ClientCAFile == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(r.config().TLSConfig.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return errors.Errorf("no certificates in client CA file: %s", r.config().TLSConfig.ClientCAFile)
+	}
+	c.ClientCAs = pool
+	c.ClientAuth = tls.VerifyClientCertIfGiven
+	return nil
+}
+
+func (p *clientPolicy) applies(req *http.Request) bool {
+	if p.pattern == verboseClientPolicy {
+		return isVerbose(req)
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if strings.HasSuffix(p.pattern, "/*") {
+		return strings.HasPrefix(path+"/", strings.TrimSuffix(p.pattern, "*"))
+	}
+	return path == strings.TrimSuffix(p.pattern, "/")
+}
+
+func (p *clientPolicy) allows(cert *x509.Certificate) bool {
+	if p.names[anyClientName] {
+		return true
+	}
+	for _, name := range certificateNames(cert) {
+		if p.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateNames gets the common name and subject alternative names of the certificate.
+func certificateNames(cert *x509.Certificate) []string {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// clientPolicyMiddleware responds with 403 Forbidden when a client policy applies to the
+// request, and the client did not present a verified certificate which it allows.
+func (r *server) clientPolicyMiddleware(context *gin.Context) {
+	var cert *x509.Certificate
+	if state := context.Request.TLS; state != nil && len(state.VerifiedChains) > 0 {
+		cert = state.VerifiedChains[0][0]
+	}
+	for _, p := range r.clientPolicies {
+		if !p.applies(context.Request) {
+			continue
+		}
+		if cert == nil {
+			r.respond(context, Verdict{StatusCode: http.StatusForbidden,
+				Result: checks.Result{Status: checks.Failed, Detail: "Client certificate required"}})
+			return
+		}
+		if !p.allows(cert) {
+			r.respond(context, Verdict{StatusCode: http.StatusForbidden,
+				Result: checks.Result{Status: checks.Failed, Detail: fmt.Sprintf("Client certificate not allowed: %s", cert.Subject.CommonName)}})
+			return
+		}
+	}
+	context.Next()
+}
@@ -0,0 +1,71 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientPolicyApplies(t *testing.T) {
+	for _, d := range []struct {
+		pattern  string
+		target   string
+		expected bool
+	}{
+		{"/ui", "/ui", true},
+		{"/ui", "/ui/", true},
+		{"/ui", "/uix", false},
+		{"/verify/*", "/verify/checks", true},
+		{"/verify/*", "/verify/service/id/web", true},
+		{"/verify/*", "/verify", true},
+		{"/verify/*", "/verifyx", false},
+		{"verbose", "/verify/checks?verbose", true},
+		{"verbose", "/verify/checks?verbose=full", true},
+		{"verbose", "/verify/checks", false},
+		{"verbose", "/verify/checks?format=html", true},
+		{"verbose", "/verify/checks?format=health", true},
+		{"verbose", "/verify/checks?format=json", false},
+	} {
+		p, err := parseClientPolicy(d.pattern + "=ops")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if applies := p.applies(httptest.NewRequest("GET", d.target, nil)); applies != d.expected {
+			t.Errorf("Policy %s for %s: want %v, got %v", d.pattern, d.target, d.expected, applies)
+		}
+	}
+}
+
+func TestClientPolicyAllows(t *testing.T) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "web-1"}, DNSNames: []string{"web.example.com"}}
+	for names, expected := range map[string]bool{
+		"web-1":           true,
+		"web.example.com": true,
+		"ops, web-1":      true,
+		"*":               true,
+		"ops":             false,
+	} {
+		p, err := parseClientPolicy("/ui=" + names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allows := p.allows(cert); allows != expected {
+			t.Errorf("Policy /ui=%s: want %v, got %v", names, expected, allows)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/version"
+	"html/template"
+	"math"
+	"sort"
+	"time"
+)
+
+const dashboardTemplateName = "dashboard"
+
+var dashboardTemplate = template.Must(template.New(dashboardTemplateName).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+{{if gt .RefreshSeconds 0}}<meta http-equiv="refresh" content="{{.RefreshSeconds}}">{{end}}
+<title>Consulate - {{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Helvetica Neue", Arial, sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.5em; }
+h2 { font-size: 1.2em; margin-top: 1.5em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: 0.4em 0.8em; border-bottom: 1px solid #ddd; }
+pre { margin: 0; white-space: pre-wrap; word-break: break-word; }
+small { color: #777; }
+.status { display: inline-block; padding: 0.1em 0.6em; border-radius: 0.3em; color: #fff; background: #777; }
+.Ok, .passing { background: #2e7d32; }
+.Warning, .warning { background: #ef6c00; }
+.Failed, .critical { background: #c62828; }
+.maintenance { background: #546e7a; }
+footer { margin-top: 2em; color: #777; font-size: 0.8em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}} <span class="status {{.Status}}">{{.Status}}</span></h1>
+{{if .Detail}}<p>{{.Detail}}</p>{{end}}
+<p>{{.Passing}} passing, {{.Warning}} warning, {{.Failing}} failing</p>
+{{range .Services}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><th>Check</th><th>Node</th><th>Status</th><th>Output</th><th>Last Change</th></tr>
+{{range .Checks}}<tr>
+<td>{{.Name}} <small>{{.CheckID}}</small></td>
+<td>{{.Node}}</td>
+<td><span class="status {{.Status}}">{{.Status}}</span></td>
+<td><pre>{{.Output}}</pre></td>
+<td>{{if .Since.IsZero}}-{{else}}{{.Since.Format "2006-01-02 15:04:05 MST"}} <small>({{.For}} ago)</small>{{end}}</td>
+</tr>
+{{end}}</table>
+{{end}}
+<footer>Generated {{.Generated.Format "2006-01-02 15:04:05 MST"}} by Consulate {{.Version}}</footer>
+</body>
+</html>
+`))
+
+type dashboard struct {
+	Title          string
+	Status         checks.ResultStatus
+	Detail         string
+	Passing        int
+	Warning        int
+	Failing        int
+	Services       []dashboardService
+	RefreshSeconds int
+	Generated      time.Time
+	Version        string
+}
+
+type dashboardService struct {
+	Name   string
+	Checks []dashboardCheck
+}
+
+type dashboardCheck struct {
+	*checks.Check
+	Since time.Time
+	For   time.Duration
+}
+
+func (r *server) html(context *gin.Context, v Verdict) {
+	now := time.Now()
+	d := dashboard{
+		Title:          context.Request.URL.Path,
+		Status:         v.Result.Status,
+		Detail:         v.Result.Detail,
+		Passing:        v.Counts[checks.StatusPassing],
+		Warning:        v.Counts[checks.StatusWarning],
+		Failing:        v.Counts[checks.StatusFailing],
+		RefreshSeconds: int(math.Ceil(r.config().DashboardRefreshInterval.Seconds())),
+		Generated:      now,
+		Version:        version.Version,
+	}
+	services := make(map[string]*dashboardService)
+	for k, c := range v.Result.Checks {
+		name := c.ServiceName
+		if name == "" {
+			name = "Node: " + c.Node
+		}
+		s, ok := services[name]
+		if !ok {
+			s = &dashboardService{Name: name}
+			services[name] = s
+		}
+		dc := dashboardCheck{Check: c}
+		if since, ok := r.tracker.since(k); ok {
+			dc.Since = since
+			dc.For = now.Sub(since).Round(time.Second)
+		}
+		s.Checks = append(s.Checks, dc)
+	}
+	for _, s := range services {
+		sort.Slice(s.Checks, func(i, j int) bool { return s.Checks[i].CheckID < s.Checks[j].CheckID })
+		d.Services = append(d.Services, *s)
+	}
+	sort.Slice(d.Services, func(i, j int) bool { return d.Services[i].Name < d.Services[j].Name })
+	context.HTML(v.StatusCode, dashboardTemplateName, d)
+}
+
+func (r *server) ui(context *gin.Context) {
+	r.processChecks(context, func(allChecks *map[string]*checks.Check) {
+		v := r.evaluate(allChecks, allChecksMatcher(), checks.HealthPassing, true)
+		v.StatusCode = r.config().SuccessStatusCode
+		r.html(context, v)
+	})
+}
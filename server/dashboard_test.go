@@ -0,0 +1,51 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDashboardRefresh(t *testing.T) {
+	for interval, expected := range map[time.Duration]string{
+		10 * time.Second:       `<meta http-equiv="refresh" content="10">`,
+		500 * time.Millisecond: `<meta http-equiv="refresh" content="1">`,
+		0:                      "",
+		-time.Second:           "",
+	} {
+		c := config.DefaultServerConfig()
+		c.DashboardRefreshInterval = interval
+		r := newServer(c)
+		recorder := httptest.NewRecorder()
+		context, router := gin.CreateTestContext(recorder)
+		router.SetHTMLTemplate(dashboardTemplate)
+		context.Request = httptest.NewRequest(http.MethodGet, uiRoute, nil)
+		r.html(context, Verdict{StatusCode: http.StatusOK, Result: checks.Result{Status: checks.Ok}})
+
+		body := recorder.Body.String()
+		if expected == "" && strings.Contains(body, `http-equiv="refresh"`) {
+			t.Errorf("Refresh interval %v: want no refresh, got %s", interval, body)
+		} else if !strings.Contains(body, expected) {
+			t.Errorf("Refresh interval %v: want %s, got %s", interval, expected, body)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	etagHeader         = "ETag"
+	ifNoneMatchHeader  = "If-None-Match"
+	cacheControlHeader = "Cache-Control"
+	weakETagPrefix     = "W/"
+	prettyETagSuffix   = "-pretty"
+)
+
+// notModified sets the caching headers of a verify response, returning true, after responding
+// with 304, when the If-None-Match header matches its ETag.  Only successful responses may be
+// cached, and only successful json responses have an ETag, because the other formats include
+// when the checks were observed.
+func (r *server) notModified(context *gin.Context, v Verdict, format string) bool {
+	if v.StatusCode < 200 || v.StatusCode > 299 {
+		return false
+	}
+	context.Header(cacheControlHeader, fmt.Sprintf("max-age=%d", r.config().CacheConfig.ConsulCacheDuration/time.Second))
+	if format != formatJSON {
+		return false
+	}
+	etag := strconv.FormatUint(resultIndex(v), 16)
+	if _, pretty := context.GetQuery(prettyQueryStringKey); pretty {
+		etag += prettyETagSuffix
+	}
+	etag = `"` + etag + `"`
+	context.Header(etagHeader, etag)
+	if !matchesETag(context.GetHeader(ifNoneMatchHeader), etag) {
+		return false
+	}
+	recordVerdict(context, v)
+	context.Status(http.StatusNotModified)
+	return true
+}
+
+// matchesETag determines whether the If-None-Match header matches the ETag, using the weak
+// comparison required for If-None-Match.
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, weakETagPrefix) == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,243 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	eventStreamContentType = "text/event-stream"
+	lastEventIDHeader      = "Last-Event-ID"
+	serviceQueryStringKey  = "service"
+	checkQueryStringKey    = "check"
+	profileQueryStringKey  = "profile"
+	checkEventName         = "check"
+	selectorEventName      = "selector"
+	serviceSelectorPrefix  = "service/"
+
+	// eventsKeepAliveInterval is the interval at which a comment is sent to idle /events streams,
+	// so that they are not closed by proxies.
+	eventsKeepAliveInterval = 15 * time.Second
+)
+
+// CheckEvent is sent by /events when the computed status of a Consul check, passing, warning or
+// failing, changes.
+type CheckEvent struct {
+	CheckID     string
+	Name        string
+	ServiceID   string
+	ServiceName string
+	OldStatus   checks.Status
+	NewStatus   checks.Status
+	OldOutput   string
+	NewOutput   string
+}
+
+// SelectorEvent is sent by /events when the status of a service or profile changes.
+type SelectorEvent struct {
+	Selector  string
+	OldStatus checks.ResultStatus
+	NewStatus checks.ResultStatus
+	OldDetail string
+	NewDetail string
+}
+
+type checkState struct {
+	name        string
+	serviceID   string
+	serviceName string
+	status      checks.Status
+	output      string
+}
+
+type selectorState struct {
+	status checks.ResultStatus
+	detail string
+}
+
+// eventStream tracks the last state sent to a client of /events, so that only changes are sent.
+type eventStream struct {
+	filtered  bool
+	matchers  []checkMatcher
+	selectors map[string]*selector
+	names     []string
+	checks    map[string]checkState
+	states    map[string]selectorState
+}
+
+func (r *server) events(context *gin.Context) {
+	stream, ok := r.newEventStream(context)
+	if !ok {
+		return
+	}
+	context.Header("Content-Type", eventStreamContentType)
+	context.Header("Cache-Control", "no-cache")
+	context.Status(http.StatusOK)
+	if context.Request.Method == http.MethodHead {
+		return
+	}
+
+	var last *snapshot
+	if id, err := strconv.ParseUint(context.GetHeader(lastEventIDHeader), 10, 64); err == nil {
+		if previous, ok := r.watcher.at(id); ok {
+			stream.update(r, previous)
+			last = previous
+		}
+	}
+	// The write deadline is extended before each write, so the write timeout only closes streams
+	// which cannot be written to.
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		r.extendWriteDeadline(context.Request, eventsKeepAliveInterval)
+		current, changed := r.watcher.latest()
+		pending := []*snapshot{current}
+		if last != nil {
+			pending = r.watcher.after(last.index)
+		}
+		for _, s := range pending {
+			for _, e := range stream.update(r, s) {
+				if _, err := fmt.Fprintf(context.Writer, "id: %d\nevent: %s\ndata: %s\n\n", s.index, e.name, e.data); err != nil {
+					return
+				}
+			}
+			last = s
+		}
+		context.Writer.Flush()
+		select {
+		case <-changed:
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(context.Writer, ":keepalive\n\n"); err != nil {
+				return
+			}
+		case <-context.Request.Context().Done():
+			return
+		case <-r.watcher.stopped():
+			return
+		}
+	}
+}
+
+func (r *server) newEventStream(context *gin.Context) (*eventStream, bool) {
+	stream := &eventStream{selectors: make(map[string]*selector)}
+	for _, service := range context.QueryArray(serviceQueryStringKey) {
+		stream.matchers = append(stream.matchers, serviceNameMatcher(service))
+		stream.selectors[serviceSelectorPrefix+service] = &selector{matcher: serviceNameMatcher(service), status: checks.HealthPassing}
+	}
+	for _, check := range context.QueryArray(checkQueryStringKey) {
+		stream.matchers = append(stream.matchers, checkIdMatcher(check))
+	}
+	for _, name := range context.QueryArray(profileQueryStringKey) {
+		sel, ok := r.profiles()[name]
+		if !ok {
+			r.respondBadRequest(context, fmt.Sprintf("Unknown profile: %v", name))
+			return nil, false
+		}
+		stream.matchers = append(stream.matchers, sel.matcher)
+		stream.selectors[profileCheckNamePrefix+name] = sel
+	}
+	stream.filtered = len(stream.matchers) > 0
+	if !stream.filtered {
+		for name, sel := range r.profiles() {
+			stream.selectors[profileCheckNamePrefix+name] = sel
+		}
+	}
+	for name := range stream.selectors {
+		stream.names = append(stream.names, name)
+	}
+	sort.Strings(stream.names)
+	return stream, true
+}
+
+func (s *eventStream) match(c *checks.Check) bool {
+	if !s.filtered {
+		return true
+	}
+	for _, m := range s.matchers {
+		if m.match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+type event struct {
+	name string
+	data []byte
+}
+
+// update records the state of the specified snapshot, returning an event for each change
+// since the previous snapshot.  When Consul could not be queried, the checks are left as they
+// were, while the services and profiles fail.
+func (s *eventStream) update(r *server, snap *snapshot) []event {
+	var events []event
+	if snap.err == nil {
+		current := make(map[string]checkState)
+		var ids []string
+		for id, c := range *snap.allChecks {
+			if s.match(c) {
+				status, _ := c.MatchStatus(checks.HealthPassing)
+				current[id] = checkState{c.Name, c.ServiceID, c.ServiceName, status, c.Output}
+				ids = append(ids, id)
+			}
+		}
+		for id := range s.checks {
+			if _, ok := current[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			old, now := s.checks[id], current[id]
+			if old.status == now.status {
+				continue
+			}
+			described := now
+			if _, ok := current[id]; !ok {
+				described = old
+			}
+			data, _ := json.Marshal(CheckEvent{CheckID: id, Name: described.name, ServiceID: described.serviceID,
+				ServiceName: described.serviceName, OldStatus: old.status, NewStatus: now.status,
+				OldOutput: old.output, NewOutput: now.output})
+			events = append(events, event{checkEventName, data})
+		}
+		s.checks = current
+	}
+
+	if s.states == nil {
+		s.states = make(map[string]selectorState)
+	}
+	for _, name := range s.names {
+		v := r.evaluateSnapshot(snap, s.selectors[name])
+		now := selectorState{v.Result.Status, v.Result.Detail}
+		old := s.states[name]
+		if old == now {
+			continue
+		}
+		data, _ := json.Marshal(SelectorEvent{Selector: name, OldStatus: old.status, NewStatus: now.status,
+			OldDetail: old.detail, NewDetail: now.detail})
+		events = append(events, event{selectorEventName, data})
+		s.states[name] = now
+	}
+	return events
+}
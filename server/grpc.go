@@ -0,0 +1,107 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"github.com/kadaan/consulate/checks"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"net"
+)
+
+// healthServer implements the gRPC Health Checking Protocol, where the service is a Consul
+// service name verified in the same way as /verify/service/name/:service.  The empty service
+// reports whether Consulate is able to communicate with Consul, in the same way as /health.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	r *server
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	allChecks, code, err := h.r.getChecks(ctx)
+	s := h.r.servingStatus(req.Service, &snapshot{allChecks: allChecks, code: code, err: err})
+	if s == healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		return nil, status.Errorf(codes.NotFound, "unknown service: %s", req.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: s}, nil
+}
+
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		snap, changed := h.r.watcher.latest()
+		if s := h.r.servingStatus(req.Service, snap); s != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: s}); err != nil {
+				return status.Error(codes.Canceled, "Stream has ended.")
+			}
+			last = s
+		}
+		select {
+		case <-changed:
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "Stream has ended.")
+		case <-h.r.watcher.stopped():
+			return status.Error(codes.Unavailable, "Consulate server is shutting down")
+		}
+	}
+}
+
+func (r *server) servingStatus(service string, s *snapshot) healthpb.HealthCheckResponse_ServingStatus {
+	if s.err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	if service == "" {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	v := r.evaluate(s.allChecks, serviceNameMatcher(service), checks.HealthPassing, false)
+	if v.Result.Status == checks.NoChecks {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+	if v.StatusCode == r.config().SuccessStatusCode {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+// startGRPCServer starts the gRPC health server, if a gRPC listen address is configured.
+func (r *server) startGRPCServer() error {
+	if r.config().GRPCListenAddress == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", r.config().GRPCListenAddress)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, &healthServer{r: r})
+	r.grpcServer = s
+	go func() {
+		r.logger.WithField("address", r.config().GRPCListenAddress).Info("Started Consulate gRPC server")
+		if err := s.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			r.logger.WithError(err).Error("Failed to start Consulate gRPC server")
+		}
+	}()
+	return nil
+}
+
+func (r *server) stopGRPCServer() {
+	if r.grpcServer != nil {
+		r.grpcServer.GracefulStop()
+		r.grpcServer = nil
+	}
+}
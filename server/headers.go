@@ -0,0 +1,84 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	statusHeader                  = "X-Consulate-Status"
+	passingHeader                 = "X-Consulate-Passing"
+	warningHeader                 = "X-Consulate-Warning"
+	failingHeader                 = "X-Consulate-Failing"
+	failingChecksHeader           = "X-Consulate-Failing-Checks"
+	maxFailingChecksHeaderLength  = 256
+	failingChecksHeaderSeparator  = ","
+	failingChecksHeaderTruncation = " (+%d more)"
+)
+
+// setSummaryHeaders summarizes the verdict in headers, so that it is available to clients, like
+// HEAD probes, which discard the body.
+func (r *server) setSummaryHeaders(context *gin.Context, v Verdict) {
+	context.Header(statusHeader, string(v.Result.Status))
+	if v.Counts == nil {
+		return
+	}
+	context.Header(passingHeader, strconv.Itoa(v.Counts[checks.StatusPassing]))
+	context.Header(warningHeader, strconv.Itoa(v.Counts[checks.StatusWarning]))
+	context.Header(failingHeader, strconv.Itoa(v.Counts[checks.StatusFailing]))
+	var failing []string
+	for id, s := range v.Statuses {
+		if s == checks.StatusFailing {
+			failing = append(failing, id)
+		}
+	}
+	if len(failing) > 0 {
+		sort.Strings(failing)
+		context.Header(failingChecksHeader, joinTruncated(failing, maxFailingChecksHeaderLength))
+	}
+}
+
+// joinTruncated joins as many of the values as fit within the maximum length, noting how many
+// were left out.
+func joinTruncated(values []string, max int) string {
+	if joined := strings.Join(values, failingChecksHeaderSeparator); len(joined) <= max {
+		return joined
+	}
+	var b strings.Builder
+	for i, v := range values {
+		separator := ""
+		if i > 0 {
+			separator = failingChecksHeaderSeparator
+		}
+		remaining := len(values) - i - 1
+		suffix := ""
+		if remaining > 0 {
+			suffix = fmt.Sprintf(failingChecksHeaderTruncation, remaining)
+		}
+		if b.Len()+len(separator)+len(v)+len(suffix) > max && i > 0 {
+			b.WriteString(fmt.Sprintf(failingChecksHeaderTruncation, len(values)-i))
+			return b.String()
+		}
+		b.WriteString(separator)
+		b.WriteString(v)
+	}
+	return b.String()
+}
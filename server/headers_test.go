@@ -0,0 +1,36 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import "testing"
+
+func TestJoinTruncated(t *testing.T) {
+	for _, d := range []struct {
+		values   []string
+		max      int
+		expected string
+	}{
+		{[]string{"a"}, 10, "a"},
+		{[]string{"a", "b", "c"}, 10, "a,b,c"},
+		{[]string{"check1", "check2", "check3"}, 19, "check1 (+2 more)"},
+		{[]string{"check1", "check2", "check3"}, 20, "check1,check2,check3"},
+		{[]string{"check1", "check2", "check3", "check4"}, 24, "check1,check2 (+2 more)"},
+		{[]string{"toolongvalue"}, 5, "toolongvalue"},
+	} {
+		if actual := joinTruncated(d.values, d.max); actual != d.expected {
+			t.Errorf("joinTruncated(%v, %d): want %q, got %q", d.values, d.max, d.expected, actual)
+		}
+	}
+}
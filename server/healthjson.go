@@ -0,0 +1,118 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/version"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	healthJSONContentType = "application/health+json"
+	healthPass            = "pass"
+	healthWarn            = "warn"
+	healthFail            = "fail"
+	componentTypeService  = "component"
+	componentTypeNode     = "system"
+)
+
+// healthResponse is the draft-inadarei-api-health-check representation of a checks.Result.
+type healthResponse struct {
+	Status      string                   `json:"status"`
+	ReleaseID   string                   `json:"releaseId,omitempty"`
+	Description string                   `json:"description,omitempty"`
+	Output      string                   `json:"output,omitempty"`
+	Checks      map[string][]healthCheck `json:"checks,omitempty"`
+}
+
+type healthCheck struct {
+	ComponentID   string `json:"componentId"`
+	ComponentType string `json:"componentType"`
+	ObservedValue string `json:"observedValue,omitempty"`
+	Status        string `json:"status"`
+	Output        string `json:"output,omitempty"`
+	Time          string `json:"time,omitempty"`
+}
+
+var healthStatuses = map[checks.Status]string{
+	checks.StatusPassing: healthPass,
+	checks.StatusWarning: healthWarn,
+	checks.StatusFailing: healthFail,
+}
+
+func (r *server) healthJSON(context *gin.Context, v Verdict) {
+	h := r.newHealthResponse(strings.TrimSuffix(context.Request.URL.Path, "/"), v)
+	var b []byte
+	if _, ok := context.GetQuery(prettyQueryStringKey); ok {
+		b, _ = json.MarshalIndent(h, "", "    ")
+	} else {
+		b, _ = json.Marshal(h)
+	}
+	context.Data(v.StatusCode, healthJSONContentType, b)
+}
+
+func (r *server) newHealthResponse(description string, v Verdict) healthResponse {
+	h := healthResponse{
+		Status:      healthFail,
+		ReleaseID:   version.Version,
+		Description: description,
+		Output:      v.Result.Detail,
+	}
+	switch v.Result.Status {
+	case checks.Ok:
+		h.Status = healthPass
+	case checks.Warning:
+		h.Status = healthWarn
+	}
+	if len(v.Result.Checks) == 0 {
+		return h
+	}
+	var observed string
+	if t := r.tracker.lastObserved(); !t.IsZero() {
+		observed = t.UTC().Format(time.RFC3339)
+	}
+	var ids []string
+	for k := range v.Result.Checks {
+		ids = append(ids, k)
+	}
+	sort.Strings(ids)
+	h.Checks = make(map[string][]healthCheck)
+	for _, k := range ids {
+		c := v.Result.Checks[k]
+		component, componentType := c.ServiceName, componentTypeService
+		if component == "" {
+			component, componentType = c.Node, componentTypeNode
+		}
+		status, ok := healthStatuses[v.Statuses[k]]
+		if !ok {
+			status = healthFail
+		}
+		key := component + ":" + c.Name
+		h.Checks[key] = append(h.Checks[key], healthCheck{
+			ComponentID:   c.CheckID,
+			ComponentType: componentType,
+			ObservedValue: c.Status,
+			Status:        status,
+			Output:        c.Output,
+			Time:          observed,
+		})
+	}
+	return h
+}
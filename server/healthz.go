@@ -0,0 +1,133 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"sort"
+	"strings"
+)
+
+const (
+	pingCheckName          = "ping"
+	consulCheckName        = "consul"
+	profileCheckNamePrefix = "profile/"
+)
+
+// healthzCheck is a named check reported by the Kubernetes-style /livez and /readyz routes.
+type healthzCheck struct {
+	name  string
+	check func() error
+}
+
+func (r *server) livez(context *gin.Context) {
+	r.healthz(context, "livez", []healthzCheck{
+		{pingCheckName, func() error { return nil }},
+	})
+}
+
+func (r *server) readyz(context *gin.Context) {
+	var allChecks *map[string]*checks.Check
+	var err error
+	fetched := false
+	getChecks := func() (*map[string]*checks.Check, error) {
+		if !fetched {
+			allChecks, _, err = r.getChecks(context.Request.Context())
+			fetched = true
+		}
+		return allChecks, err
+	}
+
+	healthzChecks := []healthzCheck{
+		{consulCheckName, func() error {
+			_, err := getChecks()
+			return err
+		}},
+	}
+	profiles := r.profiles()
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sel := profiles[name]
+		healthzChecks = append(healthzChecks, healthzCheck{profileCheckNamePrefix + name, func() error {
+			allChecks, err := getChecks()
+			if err != nil {
+				return err
+			}
+			v := r.evaluate(allChecks, sel.matcher, sel.status, false)
+			if v.Result.Status != checks.Ok {
+				if v.Result.Detail != "" {
+					return errors.New(v.Result.Detail)
+				}
+				return fmt.Errorf("%s: %d passing, %d warning, %d failing", v.Result.Status,
+					v.Counts[checks.StatusPassing], v.Counts[checks.StatusWarning], v.Counts[checks.StatusFailing])
+			}
+			return nil
+		}})
+	}
+	r.healthz(context, "readyz", healthzChecks)
+}
+
+// healthz runs the checks, which are not excluded, and writes the result in the same way as the
+// kube-apiserver.
+func (r *server) healthz(context *gin.Context, name string, healthzChecks []healthzCheck) {
+	excluded := make(map[string]bool)
+	for _, e := range context.QueryArray(excludeQueryStringKey) {
+		for _, n := range strings.Split(e, ",") {
+			excluded[strings.TrimSpace(n)] = true
+		}
+	}
+	var output bytes.Buffer
+	failed := false
+	for _, c := range healthzChecks {
+		if excluded[c.name] {
+			delete(excluded, c.name)
+			fmt.Fprintf(&output, "[+]%s excluded: ok\n", c.name)
+			continue
+		}
+		if err := c.check(); err != nil {
+			failed = true
+			context.Error(fmt.Errorf("%s check %s failed: %s", name, c.name, err)).SetType(gin.ErrorTypePrivate)
+			fmt.Fprintf(&output, "[-]%s failed: reason withheld\n", c.name)
+		} else {
+			fmt.Fprintf(&output, "[+]%s ok\n", c.name)
+		}
+	}
+	if len(excluded) > 0 {
+		var unknown []string
+		for n := range excluded {
+			unknown = append(unknown, fmt.Sprintf("%q", n))
+		}
+		sort.Strings(unknown)
+		fmt.Fprintf(&output, "warn: some health checks cannot be excluded: no matches for %s\n", strings.Join(unknown, ","))
+	}
+	if failed {
+		context.Abort()
+		context.String(r.config().ErrorStatusCode, "%s%s check failed", output.String(), name)
+		return
+	}
+	if _, verbose := context.GetQuery(verboseQueryStringKey); verbose {
+		context.String(r.config().SuccessStatusCode, "%s%s check passed", output.String(), name)
+		return
+	}
+	context.String(r.config().SuccessStatusCode, "ok")
+}
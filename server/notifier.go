@@ -0,0 +1,65 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/kadaan/consulate/config"
+	"net/http"
+)
+
+// notifier sends notifications about the Consul checks, like webhooks.
+type notifier interface {
+	// notify is called with each new snapshot, in order, from a single goroutine.
+	notify(s *snapshot)
+
+	// run sends notifications until done is closed.
+	run(done <-chan struct{})
+}
+
+// startNotifiers watches Consul on behalf of the notifiers, if there are any.  The latest snapshot
+// is notified again when the configuration is reloaded, so the notifiers apply the reloaded
+// profiles and status codes without waiting for Consul to change.
+func (r *server) startNotifiers() {
+	if len(r.notifiers) == 0 {
+		return
+	}
+	for _, n := range r.notifiers {
+		go n.run(r.watcher.stopped())
+	}
+	go func() {
+		for {
+			snap, changed := r.watcher.latest()
+			for _, n := range r.notifiers {
+				n.notify(snap)
+			}
+			select {
+			case <-changed:
+			case <-r.reloaded:
+			case <-r.watcher.stopped():
+				return
+			}
+		}
+	}()
+}
+
+// createNotifierClient creates the client which notifiers send with.  Unlike the Consul client,
+// it is not instrumented, so the client metrics only describe the requests to Consul.
+func createNotifierClient(c *config.ClientConfig) *http.Client {
+	return &http.Client{
+		Transport: cleanhttp.DefaultPooledTransport(),
+		Timeout:   c.QueryTimeout,
+	}
+}
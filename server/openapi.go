@@ -0,0 +1,357 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/version"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPISchemaPath = "#/components/schemas/"
+
+type statusDoc struct {
+	code        int
+	description string
+}
+
+type routeDoc struct {
+	path        string
+	summary     string
+	contentType string
+	schema      interface{}
+	parameters  []gin.H
+	statuses    []statusDoc
+	alternates  []string
+	headers     gin.H
+}
+
+func (r *server) openAPI(context *gin.Context) {
+	admin, _ := context.Request.Context().Value(adminListenerKey{}).(bool)
+	r.json(context, r.config().SuccessStatusCode, r.openAPIDocument(admin))
+}
+
+// openAPIDocument builds the OpenAPI document describing the routes registered by createRouter which
+// are served by the listener, which is the admin listener when admin.
+func (r *server) openAPIDocument(admin bool) gin.H {
+	docs := make(map[string]routeDoc)
+	for _, d := range r.routeDocs() {
+		docs[openAPIPath(d.path)] = d
+	}
+	schemas := gin.H{}
+	paths := gin.H{}
+	operations := make(map[string]gin.H)
+	for _, route := range r.routes {
+		if r.config().AdminListenAddress != "" && !isServedBy(route.Path, admin) {
+			continue
+		}
+		path := openAPIPath(route.Path)
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		d, ok := docs[path]
+		if !ok {
+			continue
+		}
+		operation, ok := operations[path]
+		if !ok {
+			operation = r.openAPIOperation(d, schemas)
+			operations[path] = operation
+			paths[path] = gin.H{}
+		}
+		paths[path].(gin.H)[strings.ToLower(route.Method)] = operation
+	}
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "Consulate",
+			"description": "Normalized HTTP endpoints that respond according to Consul checks.",
+			"version":     version.Version,
+		},
+		"paths":      paths,
+		"components": gin.H{"schemas": schemas},
+	}
+}
+
+func (r *server) openAPIOperation(d routeDoc, schemas gin.H) gin.H {
+	var content gin.H
+	if d.schema != nil {
+		content = gin.H{d.contentType: gin.H{"schema": openAPISchema(reflect.TypeOf(d.schema), schemas)}}
+	} else {
+		content = gin.H{d.contentType: gin.H{}}
+	}
+	for _, a := range d.alternates {
+		content[a] = gin.H{}
+	}
+	operation := gin.H{
+		"summary":   d.summary,
+		"responses": r.openAPIResponses(d.statuses, content, d.headers),
+	}
+	if len(d.parameters) > 0 {
+		operation["parameters"] = d.parameters
+	}
+	return operation
+}
+
+func (r *server) routeDocs() []routeDoc {
+	consulStatuses := []statusDoc{
+		{r.config().UnprocessableStatusCode, "Could not parse the response from Consul"},
+		{r.config().ConsulUnavailableStatusCode, "Consul unavailable"},
+	}
+	verifyStatuses := append([]statusDoc{
+		{r.config().SuccessStatusCode, "All matching Consul checks are passing"},
+		{http.StatusNotModified, "The If-None-Match header matches the ETag of the response"},
+		{r.config().PartialSuccessStatusCode, "One or more Consul checks are passing and one or more Consul checks are warning"},
+		{r.config().WarningStatusCode, "Zero Consul checks are passing and one or more Consul checks are warning"},
+		{r.config().ErrorStatusCode, "One or more Consul checks have failed"},
+		{r.config().BadRequestStatusCode, "Request could not be understood"},
+		{r.config().NoCheckStatusCode, "No matching checks"},
+	}, consulStatuses...)
+	healthStatuses := append([]statusDoc{{r.config().SuccessStatusCode, "Consulate is able to communicate with Consul"}}, consulStatuses...)
+	result := checks.Result{}
+	formats := []string{gin.MIMEHTML, healthJSONContentType}
+	verify := func(path string, summary string, params ...gin.H) routeDoc {
+		return routeDoc{path, summary, gin.MIMEJSON, result, append(params, verifyParameters()...), verifyStatuses, formats, verifyHeaders()}
+	}
+	return []routeDoc{
+		{aboutRoute, "Detailed version information about Consulate", gin.MIMEJSON, version.NewInfo(), prettyParameters(), []statusDoc{{r.config().SuccessStatusCode, "Successful call"}}, nil, nil},
+		{healthRoute, "Verifies that Consulate is running and able to communicate with Consul", gin.MIMEJSON, result, append(prettyParameters(), formatParameter()), healthStatuses, formats, nil},
+		{livezRoute, "Verifies that Consulate is running", gin.MIMEPlain, nil, healthzParameters(), []statusDoc{{r.config().SuccessStatusCode, "Consulate is live"}}, nil, nil},
+		{readyzRoute, "Verifies that Consulate is able to communicate with Consul and that all configured profiles are passing", gin.MIMEPlain, nil, healthzParameters(), []statusDoc{
+			{r.config().SuccessStatusCode, "Consulate is ready"},
+			{r.config().ErrorStatusCode, "One or more readiness checks have failed"},
+		}, nil, nil},
+		{eventsRoute, "Server-Sent Events stream of changes to the status of Consul checks, services and profiles", eventStreamContentType, nil, eventsParameters(), []statusDoc{
+			{http.StatusOK, "Successful call"},
+			{r.config().BadRequestStatusCode, "Request could not be understood"},
+		}, nil, nil},
+		{historyRoute, "Changes to the status of Consul checks and profiles recorded in the audit log", gin.MIMEJSON, []Transition{}, historyParameters(), []statusDoc{
+			{r.config().SuccessStatusCode, "Successful call"},
+			{r.config().BadRequestStatusCode, "Request could not be understood"},
+			{http.StatusNotFound, "The audit log is not enabled"},
+			{http.StatusInternalServerError, "The audit log could not be read"},
+		}, nil, nil},
+		{uiRoute, "HTML status dashboard of all Consul checks", gin.MIMEHTML, nil, nil, healthStatuses, nil, nil},
+		{openAPIRoute, "This OpenAPI document", gin.MIMEJSON, nil, prettyParameters(), []statusDoc{{r.config().SuccessStatusCode, "Successful call"}}, nil, nil},
+		{metricsRoute, "Prometheus metrics", gin.MIMEPlain, nil, nil, []statusDoc{{http.StatusOK, "Successful call"}}, nil, nil},
+		verify(verifyAllChecksRoute, "Verifies all Consul checks"),
+		verify(verifyCheckIdRoute, "Verifies the Consul check with the specified CheckID", pathParameter(verifyCheckParamKey, "The CheckID")),
+		verify(verifyCheckNameRoute, "Verifies the Consul checks with the specified check name", pathParameter(verifyCheckParamKey, "The check name")),
+		verify(verifyServiceIdRoute, "Verifies the Consul checks of the service with the specified ServiceID", pathParameter(verifyServiceParamKey, "The ServiceID")),
+		verify(verifyServiceNameRoute, "Verifies the Consul checks of the services with the specified service name", pathParameter(verifyServiceParamKey, "The service name")),
+		{configRoute, "The configuration of Consulate, without credentials", gin.MIMEJSON, config.ServerConfig{}, prettyParameters(), []statusDoc{{r.config().SuccessStatusCode, "Successful call"}}, nil, nil},
+		{cacheFlushRoute, "Deletes the cached Consul results", gin.MIMEJSON, result, prettyParameters(), []statusDoc{{r.config().SuccessStatusCode, "Flushed the Consul cache"}}, nil, nil},
+		{pprofRoute + "/*" + pprofParamKey, "Go pprof profiles", "application/octet-stream", nil, []gin.H{pathParameter(pprofParamKey, "The profile, like heap, or empty for the index")}, []statusDoc{
+			{http.StatusOK, "Successful call"},
+			{http.StatusNotFound, "Unknown profile"},
+		}, []string{gin.MIMEHTML, gin.MIMEPlain}, nil},
+	}
+}
+
+func (r *server) openAPIResponses(statuses []statusDoc, content gin.H, headers gin.H) gin.H {
+	descriptions := make(map[int][]string)
+	var codes []int
+	for _, s := range statuses {
+		if _, ok := descriptions[s.code]; !ok {
+			codes = append(codes, s.code)
+		}
+		descriptions[s.code] = append(descriptions[s.code], s.description)
+	}
+	sort.Ints(codes)
+	responses := gin.H{}
+	for _, c := range codes {
+		response := gin.H{
+			"description": strings.Join(descriptions[c], "; "),
+			"content":     content,
+		}
+		if len(headers) > 0 {
+			response["headers"] = headers
+		}
+		responses[strconv.Itoa(c)] = response
+	}
+	return responses
+}
+
+func prettyParameters() []gin.H {
+	return []gin.H{flagParameter(prettyQueryStringKey, "When present, pretty prints json responses")}
+}
+
+func verifyParameters() []gin.H {
+	return append(prettyParameters(),
+		verboseParameter(),
+		formatParameter(),
+		queryParameter(statusQueryStringKey, "Only checks whose status is worse than the specified status will cause a failure", gin.H{"type": "string", "enum": []string{
+			checks.HealthPassing.String(), checks.HealthMaintenance.String(), checks.HealthWarning.String(), checks.HealthCritical.String()}}),
+		queryParameter(indexQueryStringKey, "Holds the request until the result differs from the result with the specified X-Consulate-Index", gin.H{"type": "integer", "format": "uint64"}),
+		gin.H{"name": ifNoneMatchHeader, "in": "header", "required": false, "description": "Responds with 304 when a successful json response has a matching ETag", "schema": gin.H{"type": "string"}},
+		queryParameter(waitQueryStringKey, "The maximum duration, like 60s, to hold a request with an index, which is limited by the max wait", gin.H{"type": "string"}),
+	)
+}
+
+func verboseParameter() gin.H {
+	p := queryParameter(verboseQueryStringKey, "When present, additional details are included in responses, and when full, the type and definition of checks are too",
+		gin.H{"type": "string", "enum": []string{"", verboseFull}})
+	p["allowEmptyValue"] = true
+	return p
+}
+
+func verifyHeaders() gin.H {
+	return gin.H{
+		indexHeader:         gin.H{"description": "Identifies the result, for use with the index query string parameter", "schema": gin.H{"type": "integer", "format": "uint64"}},
+		statusHeader:        gin.H{"description": "The status of the result", "schema": gin.H{"type": "string"}},
+		passingHeader:       gin.H{"description": "The number of passing checks", "schema": gin.H{"type": "integer"}},
+		warningHeader:       gin.H{"description": "The number of warning checks", "schema": gin.H{"type": "integer"}},
+		failingHeader:       gin.H{"description": "The number of failing checks", "schema": gin.H{"type": "integer"}},
+		etagHeader:          gin.H{"description": "Identifies successful json responses, for use with the If-None-Match header", "schema": gin.H{"type": "string"}},
+		cacheControlHeader:  gin.H{"description": "The max-age is the duration that Consul results are cached", "schema": gin.H{"type": "string"}},
+		failingChecksHeader: gin.H{"description": "The comma separated CheckIDs of the failing checks, truncated to 256 characters", "schema": gin.H{"type": "string"}},
+	}
+}
+
+func healthzParameters() []gin.H {
+	return []gin.H{
+		flagParameter(verboseQueryStringKey, "When present, the result of each check is included in responses"),
+		queryParameter(excludeQueryStringKey, "Comma separated names of checks to exclude; may be repeated", gin.H{"type": "string"}),
+	}
+}
+
+func historyParameters() []gin.H {
+	return append(prettyParameters(),
+		queryParameter(fromQueryStringKey, "Only include changes at or after the specified RFC 3339 time", gin.H{"type": "string", "format": "date-time"}),
+		queryParameter(toQueryStringKey, "Only include changes before the specified RFC 3339 time", gin.H{"type": "string", "format": "date-time"}),
+		queryParameter(serviceQueryStringKey, "Only include changes to the checks of the services with the specified name; may be repeated", gin.H{"type": "string"}),
+		queryParameter(limitQueryStringKey, "The maximum number of the latest changes to include", gin.H{"type": "integer", "minimum": 1, "maximum": maxHistoryLimit, "default": defaultHistoryLimit}),
+	)
+}
+
+func eventsParameters() []gin.H {
+	return []gin.H{
+		queryParameter(serviceQueryStringKey, "Only send events for the checks and status of the services with the specified name; may be repeated", gin.H{"type": "string"}),
+		queryParameter(checkQueryStringKey, "Only send events for the check with the specified CheckID; may be repeated", gin.H{"type": "string"}),
+		queryParameter(profileQueryStringKey, "Only send events for the checks and status of the specified profile; may be repeated", gin.H{"type": "string"}),
+		{"name": lastEventIDHeader, "in": "header", "required": false, "description": "Resume the stream after the specified event", "schema": gin.H{"type": "integer"}},
+	}
+}
+
+func formatParameter() gin.H {
+	return queryParameter(formatQueryStringKey, "The response format, which otherwise is negotiated from the Accept header", gin.H{"type": "string", "enum": []string{
+		formatJSON, formatHTML, formatHealthJSON}})
+}
+
+func queryParameter(name string, description string, schema gin.H) gin.H {
+	return gin.H{"name": name, "in": "query", "required": false, "description": description, "schema": schema}
+}
+
+func flagParameter(name string, description string) gin.H {
+	p := queryParameter(name, description, gin.H{"type": "boolean"})
+	p["allowEmptyValue"] = true
+	return p
+}
+
+func pathParameter(name string, description string) gin.H {
+	return gin.H{"name": name, "in": "path", "required": true, "description": description, "schema": gin.H{"type": "string"}}
+}
+
+// openAPIPath converts a gin route path into an OpenAPI path template.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// openAPISchema derives the OpenAPI schema of the specified type from its json encoding, adding
+// named structs to schemas.
+func openAPISchema(t reflect.Type, schemas gin.H) gin.H {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return openAPISchema(t.Elem(), schemas)
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if t == durationType {
+			return gin.H{"type": "integer", "format": "int64", "description": "Duration in nanoseconds"}
+		}
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": openAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": openAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return gin.H{"type": "string", "format": "date-time"}
+		}
+		name := strings.Title(t.Name())
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = gin.H{}
+			properties := gin.H{}
+			var required []string
+			openAPIProperties(t, properties, &required, schemas)
+			schema := gin.H{"type": "object", "properties": properties}
+			if len(required) > 0 {
+				schema["required"] = required
+			}
+			schemas[name] = schema
+		}
+		return gin.H{"$ref": openAPISchemaPath + name}
+	}
+	return gin.H{}
+}
+
+func openAPIProperties(t reflect.Type, properties gin.H, required *[]string, schemas gin.H) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if f.Anonymous && tag[0] == "" && f.Type.Kind() == reflect.Struct {
+			openAPIProperties(f.Type, properties, required, schemas)
+			continue
+		}
+		name := f.Name
+		if tag[0] != "" {
+			name = tag[0]
+		}
+		properties[name] = openAPISchema(f.Type, schemas)
+		omitEmpty := false
+		for _, o := range tag[1:] {
+			omitEmpty = omitEmpty || o == "omitempty"
+		}
+		if !omitEmpty {
+			*required = append(*required, name)
+		}
+	}
+}
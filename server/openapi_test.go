@@ -0,0 +1,104 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/config"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocumentsRegisteredRoutes(t *testing.T) {
+	admin := config.DefaultServerConfig()
+	admin.AdminListenAddress = "127.0.0.1:8081"
+	for _, d := range []struct {
+		name     string
+		config   *config.ServerConfig
+		admin    bool
+		included []string
+		excluded []string
+	}{
+		{"single listener", config.DefaultServerConfig(), false, []string{aboutRoute, metricsRoute, historyRoute, openAPIRoute}, []string{configRoute, cacheFlushRoute}},
+		{"public listener", admin, false, []string{healthRoute, openAPIRoute}, []string{aboutRoute, metricsRoute, historyRoute, configRoute, cacheFlushRoute}},
+		{"admin listener", admin, true, []string{aboutRoute, metricsRoute, historyRoute, configRoute, cacheFlushRoute, "/debug/pprof/{profile}", openAPIRoute}, []string{healthRoute, verifyAllChecksRoute}},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			r := newServer(d.config)
+			router := r.createRouter()
+			paths := r.openAPIDocument(d.admin)["paths"].(gin.H)
+
+			registered := make(map[string]bool)
+			for _, route := range router.Routes() {
+				if d.config.AdminListenAddress != "" && !isServedBy(route.Path, d.admin) {
+					continue
+				}
+				path := openAPIPath(route.Path)
+				if path != "/" {
+					path = strings.TrimSuffix(path, "/")
+				}
+				registered[path] = true
+				operations, ok := paths[path]
+				if !ok {
+					t.Errorf("Route %s %s is not documented", route.Method, route.Path)
+					continue
+				}
+				if _, ok := operations.(gin.H)[strings.ToLower(route.Method)]; !ok {
+					t.Errorf("Route %s %s is documented without method %s", route.Method, route.Path, route.Method)
+				}
+			}
+			for path := range paths {
+				if !registered[path] {
+					t.Errorf("Documented path %s is not registered", path)
+				}
+			}
+			for _, path := range d.included {
+				if _, ok := paths[path]; !ok {
+					t.Errorf("Path %s is not documented", path)
+				}
+			}
+			for _, path := range d.excluded {
+				if _, ok := paths[path]; ok {
+					t.Errorf("Path %s is documented", path)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocumentsConfiguredStatusCodes(t *testing.T) {
+	c := config.DefaultServerConfig()
+	c.PartialSuccessStatusCode = 299
+	r := newServer(c)
+	r.createRouter()
+	paths := r.openAPIDocument(false)["paths"].(gin.H)
+	responses := paths[openAPIPath(verifyServiceNameRoute)].(gin.H)["get"].(gin.H)["responses"].(gin.H)
+	for _, code := range []int{c.SuccessStatusCode, c.PartialSuccessStatusCode, c.WarningStatusCode, c.ErrorStatusCode,
+		c.BadRequestStatusCode, c.NoCheckStatusCode, c.UnprocessableStatusCode, c.ConsulUnavailableStatusCode} {
+		if _, ok := responses[strconv.Itoa(code)]; !ok {
+			t.Errorf("Status code %d is not documented", code)
+		}
+	}
+	if _, ok := responses["429"]; ok {
+		t.Error("Status code 429 is documented, but not configured")
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	if p := openAPIPath(verifyCheckIdRoute); p != "/verify/checks/id/{check}" {
+		t.Errorf("openAPIPath: want %v, got %v", "/verify/checks/id/{check}", p)
+	}
+}
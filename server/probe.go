@@ -0,0 +1,130 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/pkg/errors"
+	"net/url"
+	"strings"
+)
+
+// Verdict represents the outcome of verifying Consul checks.
+type Verdict struct {
+	StatusCode int
+	Counts     map[checks.Status]int
+	Statuses   map[string]checks.Status
+	Result     checks.Result
+}
+
+type selector struct {
+	matcher checkMatcher
+	status  checks.HealthStatus
+	verbose bool
+}
+
+var selectorRoutes = []struct {
+	prefix  string
+	matcher func(string) checkMatcher
+}{
+	{verifyAllChecksRoute + "/id/", checkIdMatcher},
+	{verifyAllChecksRoute + "/name/", checkNameMatcher},
+	{"/verify/service/id/", serviceIdMatcher},
+	{"/verify/service/name/", serviceNameMatcher},
+}
+
+// parseSelector parses a verify route, like /verify/service/name/web?status=warning,
+// into the selector that the corresponding route handler would use.
+func parseSelector(route string) (*selector, error) {
+	u, err := url.Parse(route)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid route: %s", route)
+	}
+	sel := &selector{status: checks.HealthPassing}
+	if status, ok := u.Query()[statusQueryStringKey]; ok {
+		parsedStatus, parsed := checks.ParseHealthStatus(status[0])
+		if !parsed {
+			return nil, errors.Errorf("Unsupported status: %v", status[0])
+		}
+		sel.status = parsedStatus
+	}
+	_, sel.verbose = u.Query()[verboseQueryStringKey]
+
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+	if path == verifyAllChecksRoute {
+		sel.matcher = allChecksMatcher()
+		return sel, nil
+	}
+	for _, r := range selectorRoutes {
+		if strings.HasPrefix(path, r.prefix) {
+			value, err := url.PathUnescape(strings.TrimPrefix(path, r.prefix))
+			if err != nil || value == "" || strings.Contains(value, "/") {
+				break
+			}
+			sel.matcher = r.matcher(value)
+			return sel, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported route: %s", route)
+}
+
+// parseNamedSelector parses a selector which is a verify route, a profile named profile/<name>,
+// or a service name.
+func (r *server) parseNamedSelector(name string) (*selector, error) {
+	switch {
+	case name == "":
+		return nil, errors.New("no selector")
+	case strings.HasPrefix(name, "/"):
+		return parseSelector(name)
+	case strings.HasPrefix(name, profileCheckNamePrefix):
+		sel, ok := r.profiles()[strings.TrimPrefix(name, profileCheckNamePrefix)]
+		if !ok {
+			return nil, errors.Errorf("unknown profile: %s", strings.TrimPrefix(name, profileCheckNamePrefix))
+		}
+		return sel, nil
+	}
+	return &selector{matcher: serviceNameMatcher(name), status: checks.HealthPassing}, nil
+}
+
+// Probe evaluates the specified verify route once against Consul, returning the
+// same Verdict that the Consulate server would respond with.
+func Probe(c *config.ServerConfig, route string) (*Verdict, error) {
+	sel, err := parseSelector(route)
+	if err != nil {
+		return nil, err
+	}
+	r := newServer(c)
+	if err := r.createLogger(); err != nil {
+		return nil, err
+	}
+	r.createJsonAPI()
+	r.createCache()
+	r.createTracker()
+	if err := r.createTracing(); err != nil {
+		return nil, err
+	}
+	r.createClient()
+	allChecks, code, err := r.getChecks(context.Background())
+	if err != nil {
+		return &Verdict{
+			StatusCode: code,
+			Result:     checks.Result{Status: checks.Failed, Detail: err.Error()},
+		}, nil
+	}
+	v := r.evaluate(allChecks, sel.matcher, sel.status, sel.verbose)
+	return &v, nil
+}
@@ -0,0 +1,168 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	ipRateLimitKey       = "ip"
+	identityRateLimitKey = "identity"
+	retryAfterHeader     = "Retry-After"
+	minRateLimitIdle     = time.Minute
+)
+
+// rateLimiter limits the rate of requests of each client with a token bucket.  Clients which
+// have been idle long enough for their bucket to refill are forgotten.
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       rate.Limit
+	burst      int
+	idle       time.Duration
+	retryAfter string
+	swept      time.Time
+	clients    map[string]*clientLimiter
+}
+
+type clientLimiter struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func (r *server) createRateLimiter() error {
+	c := r.config().RateLimitConfig
+	if c.Rate < 0 {
+		return errors.Errorf("invalid rate limit: unsupported rate: %v", c.Rate)
+	}
+	if c.Rate == 0 {
+		return nil
+	}
+	if c.Burst < 1 {
+		return errors.Errorf("invalid rate limit: unsupported burst: %d", c.Burst)
+	}
+	if c.Key != ipRateLimitKey && c.Key != identityRateLimitKey {
+		return errors.Errorf("invalid rate limit: unsupported key: %s", c.Key)
+	}
+	r.rateLimiter = newRateLimiter(c.Rate, c.Burst)
+	if c.Key == identityRateLimitKey {
+		r.authFailureLimit = newRateLimiter(c.Rate, c.Burst)
+	}
+	r.rateLimitRequests = register(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "consulate",
+		Name:      "rate_limit_requests_total",
+		Help:      "Total number of HTTP requests which were allowed or limited by the rate limit of their client.",
+	}, []string{"group", "result"})).(*prometheus.CounterVec)
+	return nil
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	idle := time.Duration(float64(burst) / perSecond * float64(time.Second))
+	if idle < minRateLimitIdle {
+		idle = minRateLimitIdle
+	}
+	return &rateLimiter{
+		rate:       rate.Limit(perSecond),
+		burst:      burst,
+		idle:       idle,
+		retryAfter: strconv.Itoa(int(math.Ceil(1 / perSecond))),
+		clients:    make(map[string]*clientLimiter),
+	}
+}
+
+// allow takes a token from the bucket of the client, returning whether there was one.
+func (l *rateLimiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > l.idle {
+		for k, c := range l.clients {
+			if now.Sub(c.seen) > l.idle {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+	c, ok := l.clients[client]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(l.rate, l.burst)}
+		l.clients[client] = c
+	}
+	c.seen = now
+	return c.limiter.AllowN(now, 1)
+}
+
+// exhausted gets whether the bucket of the client is empty, without taking a token from it.
+func (l *rateLimiter) exhausted(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, ok := l.clients[client]
+	if !ok {
+		return false
+	}
+	reservation := c.limiter.ReserveN(now, 1)
+	defer reservation.CancelAt(now)
+	return reservation.DelayFrom(now) > 0
+}
+
+// rateLimitMiddleware responds with the rate limited status code when the client has exceeded
+// the rate limit.  Clients are keyed by their identity, when configured and authenticated, or
+// by their IP.
+func (r *server) rateLimitMiddleware(context *gin.Context) {
+	group := routeGroup(context.Request)
+	client := ""
+	if r.config().RateLimitConfig.Key == identityRateLimitKey {
+		client = context.GetString(identityKey)
+	}
+	if client == "" {
+		client = r.clientIP(context.Request).String()
+	}
+	if !r.rateLimiter.allow(client, time.Now()) {
+		r.respondRateLimited(context, group, r.rateLimiter)
+		return
+	}
+	r.countAccess(r.rateLimitRequests, "rate_limit_requests", group, allowedAccessResult)
+	context.Next()
+}
+
+// authFailureLimitMiddleware responds with the rate limited status code when the IP of the
+// client has exceeded the rate limit of failed authentication, before authenticating it again.
+// This stops the rate limit by identity, which is only applied after authentication, from
+// being bypassed by guessing credentials.
+func (r *server) authFailureLimitMiddleware(context *gin.Context) {
+	client := r.clientIP(context.Request).String()
+	if r.authFailureLimit.exhausted(client, time.Now()) {
+		r.respondRateLimited(context, routeGroup(context.Request), r.authFailureLimit)
+		return
+	}
+	context.Next()
+	if context.GetBool(authFailedKey) {
+		r.authFailureLimit.allow(client, time.Now())
+	}
+}
+
+func (r *server) respondRateLimited(context *gin.Context, group string, l *rateLimiter) {
+	r.countAccess(r.rateLimitRequests, "rate_limit_requests", group, limitedAccessResult)
+	context.Header(retryAfterHeader, l.retryAfter)
+	r.respond(context, Verdict{StatusCode: r.config().RateLimitedStatusCode,
+		Result: checks.Result{Status: checks.Failed, Detail: "Rate limit exceeded"}})
+}
@@ -0,0 +1,74 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.allow("a", now) {
+			t.Fatalf("Request %d within the burst was limited", i+1)
+		}
+	}
+	if l.allow("a", now) {
+		t.Error("Request over the burst was allowed")
+	}
+	if !l.allow("b", now) {
+		t.Error("Request from another client was limited")
+	}
+	if !l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Error("Request after a token was added was limited")
+	}
+	if l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Error("Request over the rate was allowed")
+	}
+	if l.retryAfter != "1" {
+		t.Errorf("Retry-After: want 1, got %s", l.retryAfter)
+	}
+
+	l.allow("c", now.Add(l.idle+time.Second))
+	if _, ok := l.clients["a"]; ok {
+		t.Error("Idle client was not forgotten")
+	}
+	if _, ok := l.clients["c"]; !ok {
+		t.Error("Active client was forgotten")
+	}
+}
+
+func TestRateLimiterExhausted(t *testing.T) {
+	l := newRateLimiter(1, 2)
+	now := time.Now()
+	if l.exhausted("a", now) {
+		t.Error("Unknown client was exhausted")
+	}
+	l.allow("a", now)
+	for i := 0; i < 3; i++ {
+		if l.exhausted("a", now) {
+			t.Fatalf("Check %d took a token", i+1)
+		}
+	}
+	l.allow("a", now)
+	if !l.exhausted("a", now) {
+		t.Error("Client over the burst was not exhausted")
+	}
+	if l.exhausted("a", now.Add(time.Second)) {
+		t.Error("Client was exhausted after a token was added")
+	}
+}
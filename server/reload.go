@@ -0,0 +1,194 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"github.com/kadaan/consulate/caching"
+	"github.com/kadaan/consulate/config"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// reloadableFields are the ServerConfig fields which can be changed without restarting Consulate.
+var reloadableFields = map[string]bool{
+	"ConsulAddress":               true,
+	"SuccessStatusCode":           true,
+	"PartialSuccessStatusCode":    true,
+	"WarningStatusCode":           true,
+	"ErrorStatusCode":             true,
+	"BadRequestStatusCode":        true,
+	"NoCheckStatusCode":           true,
+	"UnprocessableStatusCode":     true,
+	"ConsulUnavailableStatusCode": true,
+	"UnauthorizedStatusCode":      true,
+	"RateLimitedStatusCode":       true,
+	"DashboardRefreshInterval":    true,
+	"Profiles":                    true,
+	"ClientConfig":                true,
+	"CacheConfig":                 true,
+}
+
+// settings is the reloadable state of the server.  It is replaced as a whole on reload, so
+// in-flight requests keep using the settings they started with.
+type settings struct {
+	config     config.ServerConfig
+	profiles   map[string]*selector
+	cache      caching.Cache
+	httpClient *http.Client
+}
+
+func newServer(c *config.ServerConfig) *server {
+	r := &server{reloaded: make(chan struct{}, 1)}
+	r.current.Store(&settings{config: *c})
+	return r
+}
+
+func (r *server) settings() *settings {
+	return r.current.Load().(*settings)
+}
+
+func (r *server) config() *config.ServerConfig {
+	return &r.settings().config
+}
+
+func (r *server) profiles() map[string]*selector {
+	return r.settings().profiles
+}
+
+func (r *server) cache() caching.Cache {
+	return r.settings().cache
+}
+
+func (r *server) httpClient() *http.Client {
+	return r.settings().httpClient
+}
+
+// update replaces the current settings with a modified copy.
+func (r *server) update(f func(s *settings)) {
+	s := *r.settings()
+	f(&s)
+	r.current.Store(&s)
+}
+
+// Reload applies the reloadable fields of the config to the running server, and returns a summary
+// of what changed.  Changes to other fields are logged and ignored until Consulate is restarted.
+func (r *server) Reload(c *config.ServerConfig) ([]string, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	old := r.settings()
+	next := *old
+	var changes []string
+	var ignored []string
+	oldValue := reflect.ValueOf(&next.config).Elem()
+	newValue := reflect.ValueOf(c).Elem()
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := oldValue.Type().Field(i).Name
+		diff := configChanges(name, oldValue.Field(i), newValue.Field(i))
+		if len(diff) == 0 {
+			continue
+		}
+		if !reloadableFields[name] {
+			ignored = append(ignored, name)
+			continue
+		}
+		changes = append(changes, diff...)
+		oldValue.Field(i).Set(newValue.Field(i))
+	}
+	if len(ignored) > 0 {
+		r.logger.WithField("fields", strings.Join(ignored, ", ")).Warn("Configuration changes require a restart")
+	}
+	if len(changes) == 0 {
+		r.logger.Info("Reloaded configuration with no changes")
+		return nil, nil
+	}
+	if !reflect.DeepEqual(old.config.Profiles, next.config.Profiles) {
+		profiles, err := parseProfiles(next.config.Profiles)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range r.usedProfiles() {
+			if _, ok := profiles[name]; !ok {
+				return nil, fmt.Errorf("profile %q is in use", name)
+			}
+		}
+		next.profiles = profiles
+	}
+	if old.config.CacheConfig != next.config.CacheConfig || old.config.ConsulAddress != next.config.ConsulAddress {
+		next.cache = *caching.NewCache(next.config.CacheConfig)
+	}
+	if old.config.ClientConfig != next.config.ClientConfig {
+		next.httpClient = r.newClient(&next.config.ClientConfig)
+	}
+	r.current.Store(&next)
+	select {
+	case r.reloaded <- struct{}{}:
+	default:
+	}
+	for _, change := range changes {
+		r.logger.WithField("change", change).Info("Reloaded configuration")
+	}
+	return changes, nil
+}
+
+// configChanges describes the differences between two config values as "Field: old -> new",
+// descending into nested config structs.
+func configChanges(name string, before reflect.Value, after reflect.Value) []string {
+	if reflect.DeepEqual(before.Interface(), after.Interface()) {
+		return nil
+	}
+	if before.Kind() == reflect.Struct {
+		var changes []string
+		for i := 0; i < before.NumField(); i++ {
+			changes = append(changes, configChanges(name+"."+before.Type().Field(i).Name, before.Field(i), after.Field(i))...)
+		}
+		return changes
+	}
+	return []string{fmt.Sprintf("%s: %s -> %s", name, changeValue(before), changeValue(after))}
+}
+
+func changeValue(v reflect.Value) string {
+	if v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+		if v.Len() == 0 {
+			return "[]"
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// usedProfiles gets the profiles selected by name by the TCP check and agent check listeners,
+// webhooks and Alertmanager, which cannot be removed by a reload.
+func (r *server) usedProfiles() []string {
+	var names []string
+	for _, n := range r.notifiers {
+		if t, ok := n.(*tcpCheck); ok {
+			names = append(names, t.name)
+		}
+	}
+	for _, l := range r.agentChecks {
+		names = append(names, l.name)
+	}
+	var profiles []string
+	for _, name := range names {
+		if strings.HasPrefix(name, profileCheckNamePrefix) {
+			profiles = append(profiles, strings.TrimPrefix(name, profileCheckNamePrefix))
+		}
+	}
+	for _, w := range r.config().Webhooks {
+		profiles = append(profiles, w.Profiles...)
+	}
+	return append(profiles, r.config().AlertmanagerConfig.Profiles...)
+}
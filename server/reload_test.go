@@ -0,0 +1,106 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/config"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newReloadServer(t *testing.T) *server {
+	r := newServer(config.DefaultServerConfig())
+	r.logger = logrus.New()
+	r.logger.SetOutput(ioutil.Discard)
+	r.createCache()
+	r.createClient()
+	if err := r.createProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReload(t *testing.T) {
+	r := newReloadServer(t)
+	cache := r.cache()
+	httpClient := r.httpClient()
+
+	c := config.DefaultServerConfig()
+	c.SuccessStatusCode = 299
+	c.Profiles = map[string]string{"web": "/verify/service/name/web"}
+	c.ClientConfig.QueryTimeout = 2 * time.Second
+	c.ListenAddress = ":9090"
+	changes, err := r.Reload(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"SuccessStatusCode: 200 -> 299",
+		"Profiles: [] -> map[web:/verify/service/name/web]",
+		"ClientConfig.QueryTimeout: 5s -> 2s",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Changes => got: %q, want: %q", changes, expected)
+	}
+	if r.config().SuccessStatusCode != 299 {
+		t.Errorf("SuccessStatusCode => got: %d, want: 299", r.config().SuccessStatusCode)
+	}
+	if r.config().ListenAddress != config.DefaultListenAddress {
+		t.Errorf("ListenAddress => got: %s, want: %s", r.config().ListenAddress, config.DefaultListenAddress)
+	}
+	if _, ok := r.profiles()["web"]; !ok {
+		t.Error("Profile web was not loaded")
+	}
+	if r.cache() != cache {
+		t.Error("Cache was recreated, but its config did not change")
+	}
+	if r.httpClient() == httpClient {
+		t.Error("Client was not recreated, but its config changed")
+	}
+}
+
+func TestReloadUnchanged(t *testing.T) {
+	r := newReloadServer(t)
+	current := r.settings()
+
+	changes, err := r.Reload(config.DefaultServerConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Changes => got: %q, want none", changes)
+	}
+	if r.settings() != current {
+		t.Error("Settings were replaced, but the config did not change")
+	}
+}
+
+func TestReloadInvalidProfile(t *testing.T) {
+	r := newReloadServer(t)
+
+	c := config.DefaultServerConfig()
+	c.SuccessStatusCode = 299
+	c.Profiles = map[string]string{"web": "/unknown"}
+	if _, err := r.Reload(c); err == nil {
+		t.Fatal("expected an error for an invalid profile")
+	}
+	if r.config().SuccessStatusCode != config.DefaultSuccessStatusCode {
+		t.Errorf("SuccessStatusCode => got: %d, want: %d", r.config().SuccessStatusCode, config.DefaultSuccessStatusCode)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	verifyRouteGroup  = "verify"
+	verboseRouteGroup = "verbose"
+	metricsRouteGroup = "metrics"
+	adminRouteGroup   = "admin"
+)
+
+var (
+	routeGroups = map[string]bool{verifyRouteGroup: true, verboseRouteGroup: true, metricsRouteGroup: true, adminRouteGroup: true}
+
+	// adminRoutes and verboseRoutes are the routes in the admin and verbose groups, in addition
+	// to the pprof routes, which are in the admin group, and verbose requests, which are in the
+	// verbose group.
+	adminRoutes   = map[string]bool{historyRoute: true, configRoute: true, cacheFlushRoute: true}
+	verboseRoutes = map[string]bool{uiRoute: true, eventsRoute: true}
+)
+
+// routeGroup gets the route group of the request, which authentication and access control are
+// configured by.
+func routeGroup(req *http.Request) string {
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	switch {
+	case path == metricsRoute:
+		return metricsRouteGroup
+	case adminRoutes[path], strings.HasPrefix(path, pprofRoute):
+		return adminRouteGroup
+	case verboseRoutes[path]:
+		return verboseRouteGroup
+	}
+	if isVerbose(req) {
+		return verboseRouteGroup
+	}
+	return verifyRouteGroup
+}
+
+// splitGroupRule splits a rule into its route group and the value after =, which is empty when
+// there is no =.
+func splitGroupRule(rule string) (string, string, error) {
+	group, value := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		group, value = rule[:i], rule[i+1:]
+	}
+	if !routeGroups[group] {
+		return "", "", errors.Errorf("unsupported group: %s", group)
+	}
+	return group, value, nil
+}
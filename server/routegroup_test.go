@@ -0,0 +1,54 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteGroup(t *testing.T) {
+	for target, expected := range map[string]string{
+		"/verify/checks":                     verifyRouteGroup,
+		"/verify/service/id/web?verbose":     verboseRouteGroup,
+		"/verify/checks?verbose=full":        verboseRouteGroup,
+		"/verify/checks?format=json":         verifyRouteGroup,
+		"/verify/checks?format=html":         verboseRouteGroup,
+		"/verify/checks?format=health":       verboseRouteGroup,
+		"/ui/":                               verboseRouteGroup,
+		"/events":                            verboseRouteGroup,
+		"/metrics":                           metricsRouteGroup,
+		"/history?from=2020-01-01T00:00:00Z": adminRouteGroup,
+		"/about":                             verifyRouteGroup,
+	} {
+		if group := routeGroup(httptest.NewRequest("GET", target, nil)); group != expected {
+			t.Errorf("Group of %s: want %s, got %s", target, expected, group)
+		}
+	}
+}
+
+func TestRouteGroupAccept(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"application/json":        verifyRouteGroup,
+		"text/html":               verboseRouteGroup,
+		"application/health+json": verboseRouteGroup,
+	} {
+		req := httptest.NewRequest("GET", "/verify/checks", nil)
+		req.Header.Set("Accept", accept)
+		if group := routeGroup(req); group != expected {
+			t.Errorf("Group of Accept %s: want %s, got %s", accept, expected, group)
+		}
+	}
+}
@@ -25,13 +25,24 @@ import (
 	"github.com/kadaan/consulate/checks"
 	"github.com/kadaan/consulate/client"
 	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/logging"
 	"github.com/kadaan/consulate/spi"
+	"github.com/kadaan/consulate/statsd"
 	"github.com/kadaan/consulate/version"
 	"github.com/kadaan/go-gin-prometheus"
 	"github.com/prometheus/client_golang/prometheus"
-	"log"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -42,9 +53,25 @@ const (
 	verifyServiceParamTag  = ":" + verifyServiceParamKey
 	prettyQueryStringKey   = "pretty"
 	verboseQueryStringKey  = "verbose"
+	verboseFull            = "full"
 	statusQueryStringKey   = "status"
+	excludeQueryStringKey  = "exclude"
+	formatQueryStringKey   = "format"
+	formatJSON             = "json"
+	formatHTML             = "html"
+	formatHealthJSON       = "health"
 	aboutRoute             = "/about"
 	healthRoute            = "/health"
+	uiRoute                = "/ui"
+	livezRoute             = "/livez"
+	readyzRoute            = "/readyz"
+	eventsRoute            = "/events"
+	historyRoute           = "/history"
+	openAPIRoute           = "/openapi.json"
+	metricsRoute           = "/metrics"
+	configRoute            = "/config"
+	cacheFlushRoute        = "/cache/flush"
+	pprofRoute             = "/debug/pprof"
 	verifyAllChecksRoute   = "/verify/checks"
 	verifyCheckIdRoute     = verifyAllChecksRoute + "/id/" + verifyCheckParamTag
 	verifyCheckNameRoute   = verifyAllChecksRoute + "/name/" + verifyCheckParamTag
@@ -64,51 +91,148 @@ const (
 )
 
 type server struct {
-	config     config.ServerConfig
-	httpServer http.Server
-	httpClient http.Client
-	jsonApi    jsoniter.API
-	cache      caching.Cache
+	current           atomic.Value
+	reloadMu          sync.Mutex
+	httpServer        http.Server
+	jsonApi           jsoniter.API
+	tracker           *statusTracker
+	watcher           *watcher
+	notifiers         []notifier
+	reloaded          chan struct{}
+	statsd            statsd.Client
+	logger            *logrus.Logger
+	tracer            trace.Tracer
+	propagator        propagation.TextMapPropagator
+	tracerProvider    *sdktrace.TracerProvider
+	grpcServer        *grpc.Server
+	audit             *auditLog
+	agentChecks       []*agentCheckListener
+	certificate       *certificate
+	clientPolicies    []*clientPolicy
+	authenticators    map[string][]authenticator
+	authFailures      *prometheus.CounterVec
+	authDone          chan struct{}
+	access            *accessControl
+	accessRequests    *prometheus.CounterVec
+	trustedProxies    []*net.IPNet
+	rateLimiter       *rateLimiter
+	authFailureLimit  *rateLimiter
+	rateLimitRequests *prometheus.CounterVec
+	adminServer       *http.Server
+	routes            gin.RoutesInfo
 }
 
 // NewServer create a new Consulate server.
 func NewServer(c *config.ServerConfig) spi.Server {
-	svr := &server{config: *c}
-	return svr
+	return newServer(c)
 }
 
-// Start begins the Server.
+// Start begins the Server.  When it fails, whatever was already created or started is released.
 func (r *server) Start() (spi.RunningServer, error) {
 	if state == stopped {
+		if err := r.create(); err != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), r.config().ShutdownTimeout)
+			defer cancel()
+			r.release(ctx)
+			return nil, err
+		}
 		state = started
-		r.createJsonAPI()
-		r.createCache()
-		r.createServer()
-		r.createClient()
-		var err error
-		go func() {
-			log.Printf("Started Consulate server on %s\n", r.config.ListenAddress)
-			if err = r.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Printf("Failed to start Consulate server: %s\n", err)
-			}
-		}()
-		return r, err
+		r.startNotifiers()
+		for _, s := range r.httpServers() {
+			go r.serve(s)
+		}
+		return r, nil
 	}
 	return nil, errors.New("cannot start server because it is already running")
 }
 
+// create creates the server, and starts the listeners other than the HTTP servers.
+func (r *server) create() error {
+	if err := r.createLogger(); err != nil {
+		return err
+	}
+	if err := r.createProfiles(); err != nil {
+		return err
+	}
+	if err := r.createWebhooks(); err != nil {
+		return err
+	}
+	if err := r.createAlertmanager(); err != nil {
+		return err
+	}
+	if err := r.createAudit(); err != nil {
+		return err
+	}
+	if err := r.createTCPChecks(); err != nil {
+		return err
+	}
+	if err := r.createStatsD(); err != nil {
+		return err
+	}
+	if err := r.createTracing(); err != nil {
+		return err
+	}
+	if err := r.createClientPolicies(); err != nil {
+		return err
+	}
+	if err := r.createAuth(); err != nil {
+		return err
+	}
+	if err := r.createAccessControl(); err != nil {
+		return err
+	}
+	if err := r.createRateLimiter(); err != nil {
+		return err
+	}
+	r.createJsonAPI()
+	r.createCache()
+	r.createTracker()
+	r.createServer()
+	if err := r.createTLS(); err != nil {
+		return err
+	}
+	r.createClient()
+	if err := r.createWatcher(); err != nil {
+		return err
+	}
+	if err := r.startAgentChecks(); err != nil {
+		return err
+	}
+	return r.startGRPCServer()
+}
+
 func (r *server) Stop() {
 	if state == started {
-		log.Println("Shutting down Consulate server...")
-		ctx, cancel := context.WithTimeout(context.Background(), r.config.ShutdownTimeout)
+		r.logger.Info("Shutting down Consulate server")
+		ctx, cancel := context.WithTimeout(context.Background(), r.config().ShutdownTimeout)
 		defer func() {
 			cancel()
 			state = stopped
 		}()
-		if err := r.httpServer.Shutdown(ctx); err != nil {
-			log.Panicf("Consulate server shutdown failed:%s", err)
+		if err := r.shutdownServers(ctx); err != nil {
+			r.logger.WithError(err).Panic("Consulate server shutdown failed")
 		}
-		log.Println("Consulate server shutdown")
+		r.release(ctx)
+		r.logger.Info("Consulate server shutdown")
+	}
+}
+
+// release stops the listeners and goroutines, and closes the resources, which have been started or
+// created, other than the HTTP servers.
+func (r *server) release(ctx context.Context) {
+	if r.watcher != nil {
+		r.watcher.close()
+	}
+	r.stopGRPCServer()
+	r.stopAgentChecks()
+	r.stopTLS()
+	r.stopAuth()
+	r.stopTracing(ctx)
+	if r.statsd != nil {
+		r.statsd.Close()
+	}
+	if r.audit != nil {
+		r.audit.close()
 	}
 }
 
@@ -116,16 +240,59 @@ func (r *server) createRouter() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.UseRawPath = true
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	router.Use(r.accessLogMiddleware)
+	router.Use(gin.RecoveryWithWriter(r.logger.WriterLevel(logrus.ErrorLevel)))
+	if r.config().AdminListenAddress != "" {
+		router.Use(r.listenerMiddleware)
+	}
+	// The metrics route is registered with the Prometheus middleware, so only the middleware
+	// before it applies to the metrics route.
+	if r.access != nil {
+		router.Use(r.accessMiddleware)
+	}
+	if len(r.clientPolicies) > 0 {
+		router.Use(r.clientPolicyMiddleware)
+	}
+	// Clients are rate limited before authentication, unless they are keyed by their identity,
+	// which is only known after it.  Then, failed authentication is rate limited by IP before it.
+	identityRateLimit := r.config().RateLimitConfig.Key == identityRateLimitKey
+	if r.rateLimiter != nil && !identityRateLimit {
+		router.Use(r.rateLimitMiddleware)
+	}
+	if len(r.authenticators) > 0 {
+		if r.authFailureLimit != nil {
+			router.Use(r.authFailureLimitMiddleware)
+		}
+		router.Use(r.authMiddleware)
+	}
+	if r.rateLimiter != nil && identityRateLimit {
+		router.Use(r.rateLimitMiddleware)
+	}
 	r.attachPrometheusMiddleware(router)
+	if r.config().TracingConfig.Endpoint != "" {
+		router.Use(r.tracingMiddleware)
+	}
+	if r.config().StatsDConfig.Address != "" {
+		router.Use(r.statsDMiddleware)
+	}
+	router.SetHTMLTemplate(dashboardTemplate)
 	r.handle(router, aboutRoute, r.about)
 	r.handle(router, healthRoute, r.health)
+	r.handle(router, livezRoute, r.livez)
+	r.handle(router, readyzRoute, r.readyz)
+	r.handle(router, eventsRoute, r.events)
+	r.handle(router, historyRoute, r.history)
+	r.handle(router, uiRoute, r.ui)
+	r.handle(router, openAPIRoute, r.openAPI)
 	r.handle(router, verifyAllChecksRoute, r.verifyAllChecks)
 	r.handle(router, verifyCheckIdRoute, r.verifyCheckId)
 	r.handle(router, verifyCheckNameRoute, r.verifyCheckName)
 	r.handle(router, verifyServiceIdRoute, r.verifyServiceId)
 	r.handle(router, verifyServiceNameRoute, r.verifyServiceName)
+	if r.config().AdminListenAddress != "" {
+		r.handleAdmin(router)
+	}
+	r.routes = router.Routes()
 	return router
 }
 
@@ -166,29 +333,43 @@ func (r *server) attachPrometheusMiddleware(engine *gin.Engine) {
 		[]string{"code", "method", "url"},
 	)
 
-	prometheus.MustRegister(counter, duration, requestSize, responseSize)
-
 	b := ginprometheus.NewBuilder()
-	b.Counter(counter)
-	b.Duration(duration)
-	b.RequestSize(requestSize)
-	b.ResponseSize(responseSize)
-	b.UrlMapping(func(c *gin.Context) string {
-		url := strings.TrimSuffix(c.Request.URL.Path, "/")
-		for _, param := range c.Params {
-			if param.Key == verifyCheckParamKey {
-				url = strings.Replace(url, param.Value, verifyCheckParamTag, 1)
-				break
-			} else if param.Key == verifyServiceParamKey {
-				url = strings.Replace(url, param.Value, verifyServiceParamTag, 1)
-				break
-			}
-		}
-		return url
-	})
+	b.Counter(register(counter).(*prometheus.CounterVec))
+	b.Duration(register(duration).(*prometheus.SummaryVec))
+	b.RequestSize(register(requestSize).(*prometheus.SummaryVec))
+	b.ResponseSize(register(responseSize).(*prometheus.SummaryVec))
+	b.UrlMapping(metricsURL)
 	b.Use(engine)
 }
 
+// metricsURL gets the path of the request, with the check or service replaced by its
+// parameter, so that metrics are not labelled by every check and service.
+func metricsURL(c *gin.Context) string {
+	url := strings.TrimSuffix(c.Request.URL.Path, "/")
+	for _, param := range c.Params {
+		if param.Key == verifyCheckParamKey {
+			url = strings.Replace(url, param.Value, verifyCheckParamTag, 1)
+			break
+		} else if param.Key == verifyServiceParamKey {
+			url = strings.Replace(url, param.Value, verifyServiceParamTag, 1)
+			break
+		}
+	}
+	return url
+}
+
+// register registers the collector with Prometheus, returning the previously registered
+// collector if the server has already been started once in this process.
+func register(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
 func (r *server) handle(router *gin.Engine, relativePath string, handler gin.HandlerFunc) {
 	router.GET(relativePath, handler)
 	router.GET(relativePath+"/", handler)
@@ -197,7 +378,31 @@ func (r *server) handle(router *gin.Engine, relativePath string, handler gin.Han
 }
 
 func (r *server) createClient() {
-	r.httpClient = *client.CreateClient(&r.config.ClientConfig)
+	r.update(func(s *settings) {
+		s.httpClient = r.newClient(&s.config.ClientConfig)
+	})
+}
+
+// newClient creates the Consul client, with the StatsD and tracing middleware when they are
+// enabled.
+func (r *server) newClient(c *config.ClientConfig) *http.Client {
+	var middleware []client.Middleware
+	if r.statsd != nil {
+		middleware = append(middleware, client.StatsD(r.statsd))
+	}
+	if r.tracerProvider != nil {
+		middleware = append(middleware, client.Tracing(r.tracer, r.propagator))
+	}
+	return client.CreateClient(c, middleware...)
+}
+
+func (r *server) createLogger() error {
+	logger, err := logging.NewLogger(r.config().LogConfig)
+	if err != nil {
+		return err
+	}
+	r.logger = logger
+	return nil
 }
 
 func (r *server) createJsonAPI() {
@@ -208,15 +413,97 @@ func (r *server) createServer() {
 	router := r.createRouter()
 
 	r.httpServer = http.Server{
-		Addr:         r.config.ListenAddress,
+		Addr:         r.config().ListenAddress,
 		Handler:      router,
-		ReadTimeout:  r.config.ReadTimeout,
-		WriteTimeout: r.config.WriteTimeout,
+		ReadTimeout:  r.config().ReadTimeout,
+		WriteTimeout: r.config().WriteTimeout,
+		ConnContext:  withConn,
+	}
+	r.createAdminServer(router)
+}
+
+// httpServers gets the public server, and the admin server when it is enabled.
+func (r *server) httpServers() []*http.Server {
+	servers := []*http.Server{&r.httpServer}
+	if r.adminServer != nil {
+		servers = append(servers, r.adminServer)
 	}
+	return servers
+}
+
+func (r *server) serve(s *http.Server) {
+	var err error
+	r.logger.WithField("address", s.Addr).Info("Started Consulate server")
+	if s.TLSConfig != nil {
+		err = s.ListenAndServeTLS("", "")
+	} else {
+		err = s.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		r.logger.WithFields(logrus.Fields{"address": s.Addr, "error": err}).Error("Failed to start Consulate server")
+	}
+}
+
+// shutdownServers gracefully shuts down the servers together, so they share the shutdown
+// timeout, returning the first error.
+func (r *server) shutdownServers(ctx context.Context) error {
+	servers := r.httpServers()
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		go func(s *http.Server) {
+			errs <- s.Shutdown(ctx)
+		}(s)
+	}
+	var first error
+	for range servers {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
 }
 
 func (r *server) createCache() {
-	r.cache = *caching.NewCache(r.config.CacheConfig)
+	r.update(func(s *settings) {
+		s.cache = *caching.NewCache(s.config.CacheConfig)
+	})
+}
+
+func (r *server) createProfiles() error {
+	profiles, err := parseProfiles(r.config().Profiles)
+	if err != nil {
+		return err
+	}
+	r.update(func(s *settings) {
+		s.profiles = profiles
+	})
+	return nil
+}
+
+func parseProfiles(routes map[string]string) (map[string]*selector, error) {
+	profiles := make(map[string]*selector)
+	for name, route := range routes {
+		sel, err := parseSelector(route)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %s", name, err)
+		}
+		profiles[name] = sel
+	}
+	return profiles, nil
+}
+
+func (r *server) createWatcher() error {
+	if r.config().WatchInterval <= 0 {
+		return fmt.Errorf("invalid watcher: unsupported watch interval: %v", r.config().WatchInterval)
+	}
+	r.watcher = newWatcher(func() (*map[string]*checks.Check, int, error) {
+		return r.getChecks(context.Background())
+	}, r.config().WatchInterval)
+	return nil
+}
+
+func (r *server) createTracker() {
+	r.tracker = newStatusTracker()
 }
 
 func (r *server) json(context *gin.Context, code int, obj interface{}) {
@@ -228,7 +515,7 @@ func (r *server) json(context *gin.Context, code int, obj interface{}) {
 	}
 }
 
-func (r *server) abortWithStatusJSON(context *gin.Context, code int, obj interface{}) {
+func (r *server) abort(context *gin.Context, obj interface{}) {
 	var message string
 	switch obj.(type) {
 	case checks.Result:
@@ -239,16 +526,18 @@ func (r *server) abortWithStatusJSON(context *gin.Context, code int, obj interfa
 	}
 	context.Error(errors.New(message)).SetType(gin.ErrorTypePrivate)
 	context.Abort()
-	r.json(context, code, obj)
 }
 
 func (r *server) about(context *gin.Context) {
-	r.json(context, r.config.SuccessStatusCode, version.NewInfo())
+	r.json(context, r.config().SuccessStatusCode, version.NewInfo())
 }
 
 func (r *server) health(context *gin.Context) {
+	if _, ok := r.requireFormat(context); !ok {
+		return
+	}
 	r.processChecks(context, func(allChecks *map[string]*checks.Check) {
-		r.json(context, r.config.SuccessStatusCode, checks.Result{Status: checks.Ok})
+		r.respond(context, Verdict{StatusCode: r.config().SuccessStatusCode, Result: checks.Result{Status: checks.Ok}})
 	})
 }
 
@@ -263,134 +552,272 @@ func (m *checkMatcher) match(c *checks.Check) bool {
 	return m.matcher(c)
 }
 
-func (r *server) verifyAllChecks(context *gin.Context) {
-	matcher := checkMatcher{
+func allChecksMatcher() checkMatcher {
+	return checkMatcher{
 		noChecksErrorMessage: "No checks",
 		matcher:              func(c *checks.Check) bool { return true },
 	}
-	r.verifyChecks(context, matcher)
 }
 
-func (r *server) verifyCheckId(context *gin.Context) {
-	check := context.Param(verifyCheckParamKey)
-	matcher := checkMatcher{
+func checkIdMatcher(check string) checkMatcher {
+	return checkMatcher{
 		noChecksErrorMessage: fmt.Sprintf("No checks with CheckID: %s", check),
 		matcher:              func(c *checks.Check) bool { return c.IsCheckId(check) },
 	}
-	r.verifyChecks(context, matcher)
 }
 
-func (r *server) verifyCheckName(context *gin.Context) {
-	check := context.Param(verifyCheckParamKey)
-	matcher := checkMatcher{
+func checkNameMatcher(check string) checkMatcher {
+	return checkMatcher{
 		noChecksErrorMessage: fmt.Sprintf("No checks with CheckName: %s", check),
 		matcher:              func(c *checks.Check) bool { return c.IsCheckName(check) },
 	}
-	r.verifyChecks(context, matcher)
 }
 
-func (r *server) verifyServiceId(context *gin.Context) {
-	service := context.Param(verifyServiceParamKey)
-	matcher := checkMatcher{
+func serviceIdMatcher(service string) checkMatcher {
+	return checkMatcher{
 		noChecksErrorMessage: fmt.Sprintf("No checks for services with ServiceId: %s", service),
 		matcher:              func(c *checks.Check) bool { return c.IsServiceId(service) },
 	}
-	r.verifyChecks(context, matcher)
 }
 
-func (r *server) verifyServiceName(context *gin.Context) {
-	service := context.Param(verifyServiceParamKey)
-	matcher := checkMatcher{
+func serviceNameMatcher(service string) checkMatcher {
+	return checkMatcher{
 		noChecksErrorMessage: fmt.Sprintf("No checks for services with ServiceName: %s", service),
 		matcher:              func(c *checks.Check) bool { return c.IsServiceName(service) },
 	}
-	r.verifyChecks(context, matcher)
+}
+
+func (r *server) verifyAllChecks(context *gin.Context) {
+	r.verifyChecks(context, allChecksMatcher())
+}
+
+func (r *server) verifyCheckId(context *gin.Context) {
+	r.verifyChecks(context, checkIdMatcher(context.Param(verifyCheckParamKey)))
+}
+
+func (r *server) verifyCheckName(context *gin.Context) {
+	r.verifyChecks(context, checkNameMatcher(context.Param(verifyCheckParamKey)))
+}
+
+func (r *server) verifyServiceId(context *gin.Context) {
+	r.verifyChecks(context, serviceIdMatcher(context.Param(verifyServiceParamKey)))
+}
+
+func (r *server) verifyServiceName(context *gin.Context) {
+	r.verifyChecks(context, serviceNameMatcher(context.Param(verifyServiceParamKey)))
 }
 
 func (r *server) verifyChecks(context *gin.Context, matcher checkMatcher) {
-	r.processChecks(context, func(allChecks *map[string]*checks.Check) {
-		_, isVerbose := context.GetQuery(verboseQueryStringKey)
-		s := r.getStatus(context)
-
-		var checkCount = 0
-		var verifiedCheckCount = 0
-		var statusCounts = map[checks.Status]int{
-			checks.StatusPassing: 0,
-			checks.StatusWarning: 0,
-			checks.StatusFailing: 0,
-		}
-		var matchedChecks map[string]*checks.Check
-		matchedChecks = make(map[string]*checks.Check)
-		for k, v := range *allChecks {
-			checkCount++
-			if matcher.match(v) {
-				verifiedCheckCount++
-				m, e := v.MatchStatus(s)
-				if e != nil {
-					r.abortWithStatusJSON(context, r.config.UnprocessableStatusCode,
-						checks.Result{Status: checks.Failed, Detail: e.Error()})
-				}
-				statusCounts[m] = statusCounts[m] + 1
-				if m != checks.StatusPassing || isVerbose {
-					matchedChecks[k] = v
-				}
+	s, ok := r.getStatus(context)
+	if !ok {
+		return
+	}
+	format, ok := r.requireFormat(context)
+	if !ok {
+		return
+	}
+	q, ok := r.getBlockingQuery(context)
+	if !ok {
+		return
+	}
+	verbose := context.Query(verboseQueryStringKey)
+	isVerbose := isVerbose(context.Request)
+	evaluate := func(allChecks *map[string]*checks.Check) Verdict {
+		return r.traceEvaluation(context.Request.Context(), func() Verdict {
+			v := r.evaluate(allChecks, matcher, s, isVerbose)
+			if verbose != verboseFull {
+				v.Result.Checks = withoutDefinitions(v.Result.Checks)
 			}
+			return v
+		})
+	}
+	var v Verdict
+	if q != nil {
+		if v, ok = r.block(context, q, evaluate); !ok {
+			return
 		}
-		if statusCounts[checks.StatusFailing] > 0 {
-			r.abortWithStatusJSON(context, r.config.ErrorStatusCode,
-				checks.Result{Status: checks.Failed, Counts: statusCounts, Checks: matchedChecks})
-		} else if statusCounts[checks.StatusPassing] == 0 && statusCounts[checks.StatusWarning] > 0 {
-			r.abortWithStatusJSON(context, r.config.WarningStatusCode,
-				checks.Result{Status: checks.Failed, Counts: statusCounts, Checks: matchedChecks})
-		} else if statusCounts[checks.StatusWarning] > 0 {
-			r.abortWithStatusJSON(context, r.config.PartialSuccessStatusCode,
-				checks.Result{Status: checks.Warning, Counts: statusCounts, Checks: matchedChecks})
-		} else if checkCount == 0 || verifiedCheckCount == 0 {
-			r.abortWithStatusJSON(context, r.config.NoCheckStatusCode,
-				checks.Result{Status: checks.NoChecks, Detail: matcher.noChecksErrorMessage})
-		} else {
-			r.json(context, r.config.SuccessStatusCode, checks.Result{Status: checks.Ok, Checks: matchedChecks})
-		}
-	})
+	} else if allChecks, code, err := r.getChecks(context.Request.Context()); err != nil {
+		v = Verdict{StatusCode: code, Result: checks.Result{Status: checks.Failed, Detail: err.Error()}}
+	} else {
+		v = evaluate(allChecks)
+	}
+	context.Header(indexHeader, strconv.FormatUint(resultIndex(v), 10))
+	r.setSummaryHeaders(context, v)
+	if r.notModified(context, v, format) {
+		return
+	}
+	r.respond(context, v)
 }
 
-func (r *server) processChecks(context *gin.Context, handler checkHandler) {
-	target := fmt.Sprintf(consulChecksUrl, r.config.ConsulAddress)
-	var allChecks *map[string]*checks.Check
-	if cachedChecks, ok := r.cache.Get(target); ok {
-		allChecks = cachedChecks.(*map[string]*checks.Check)
-	} else {
-		resp, err := r.httpClient.Get(target)
-		if resp != nil && resp.Body != nil {
-			defer resp.Body.Close()
-		}
-		if err != nil {
-			r.abortWithStatusJSON(context, r.config.ConsulUnavailableStatusCode,
-				checks.Result{Status: checks.Failed, Detail: err.Error()})
-			return
+func (r *server) respond(context *gin.Context, v Verdict) {
+	recordVerdict(context, v)
+	if v.StatusCode != r.config().SuccessStatusCode {
+		r.abort(context, v.Result)
+	}
+	format, _ := r.getFormat(context)
+	switch format {
+	case formatHTML:
+		r.html(context, v)
+	case formatHealthJSON:
+		r.healthJSON(context, v)
+	default:
+		r.json(context, v.StatusCode, v.Result)
+	}
+}
+
+// getFormat gets the response format from the format query string parameter, falling back
+// to the Accept header.  Unsupported formats fall back to json.
+func (r *server) getFormat(context *gin.Context) (string, bool) {
+	return requestFormat(context.Request)
+}
+
+func requestFormat(req *http.Request) (string, bool) {
+	format, formatSpecified := req.URL.Query()[formatQueryStringKey]
+	if !formatSpecified {
+		context := &gin.Context{Request: req}
+		switch context.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML, healthJSONContentType) {
+		case gin.MIMEHTML:
+			return formatHTML, true
+		case healthJSONContentType:
+			return formatHealthJSON, true
 		}
-		err = r.jsonApi.NewDecoder(resp.Body).Decode(&allChecks)
-		if err != nil {
-			r.abortWithStatusJSON(context, r.config.UnprocessableStatusCode,
-				checks.Result{Status: checks.Failed, Detail: err.Error()})
-			return
+		return formatJSON, true
+	}
+	switch format[0] {
+	case formatJSON, formatHTML, formatHealthJSON:
+		return format[0], true
+	}
+	return formatJSON, false
+}
+
+// isVerbose gets whether the response to the request includes the output of passing checks,
+// which is requested by the verbose query string parameter or any format other than json.
+func isVerbose(req *http.Request) bool {
+	if _, verbose := req.URL.Query()[verboseQueryStringKey]; verbose {
+		return true
+	}
+	format, _ := requestFormat(req)
+	return format != formatJSON
+}
+
+func (r *server) requireFormat(context *gin.Context) (string, bool) {
+	format, ok := r.getFormat(context)
+	if !ok {
+		r.respondBadRequest(context, fmt.Sprintf("Unsupported format: %v", context.Query(formatQueryStringKey)))
+	}
+	return format, ok
+}
+
+// evaluate verifies the checks selected by the matcher against the specified status.
+func (r *server) evaluate(allChecks *map[string]*checks.Check, matcher checkMatcher, s checks.HealthStatus, isVerbose bool) Verdict {
+	var checkCount = 0
+	var verifiedCheckCount = 0
+	var statusCounts = map[checks.Status]int{
+		checks.StatusPassing: 0,
+		checks.StatusWarning: 0,
+		checks.StatusFailing: 0,
+	}
+	var matchedChecks map[string]*checks.Check
+	matchedChecks = make(map[string]*checks.Check)
+	var statuses = make(map[string]checks.Status)
+	for k, v := range *allChecks {
+		checkCount++
+		if matcher.match(v) {
+			verifiedCheckCount++
+			m, e := v.MatchStatus(s)
+			if e != nil {
+				return Verdict{StatusCode: r.config().UnprocessableStatusCode,
+					Result: checks.Result{Status: checks.Failed, Detail: e.Error()}}
+			}
+			statusCounts[m] = statusCounts[m] + 1
+			statuses[k] = m
+			if m != checks.StatusPassing || isVerbose {
+				matchedChecks[k] = v
+			}
 		}
-		r.cache.Set(target, allChecks)
+	}
+	if statusCounts[checks.StatusFailing] > 0 {
+		return Verdict{StatusCode: r.config().ErrorStatusCode, Counts: statusCounts, Statuses: statuses,
+			Result: checks.Result{Status: checks.Failed, Counts: statusCounts, Checks: matchedChecks}}
+	} else if statusCounts[checks.StatusPassing] == 0 && statusCounts[checks.StatusWarning] > 0 {
+		return Verdict{StatusCode: r.config().WarningStatusCode, Counts: statusCounts, Statuses: statuses,
+			Result: checks.Result{Status: checks.Failed, Counts: statusCounts, Checks: matchedChecks}}
+	} else if statusCounts[checks.StatusWarning] > 0 {
+		return Verdict{StatusCode: r.config().PartialSuccessStatusCode, Counts: statusCounts, Statuses: statuses,
+			Result: checks.Result{Status: checks.Warning, Counts: statusCounts, Checks: matchedChecks}}
+	} else if checkCount == 0 || verifiedCheckCount == 0 {
+		return Verdict{StatusCode: r.config().NoCheckStatusCode, Counts: statusCounts, Statuses: statuses,
+			Result: checks.Result{Status: checks.NoChecks, Detail: matcher.noChecksErrorMessage}}
+	}
+	return Verdict{StatusCode: r.config().SuccessStatusCode, Counts: statusCounts, Statuses: statuses,
+		Result: checks.Result{Status: checks.Ok, Checks: matchedChecks}}
+}
+
+// withoutDefinitions copies the checks without their type and definition, which are only
+// included in responses when verbose=full.
+func withoutDefinitions(matchedChecks map[string]*checks.Check) map[string]*checks.Check {
+	if matchedChecks == nil {
+		return nil
+	}
+	result := make(map[string]*checks.Check, len(matchedChecks))
+	for k, v := range matchedChecks {
+		result[k] = v.WithoutDefinition()
+	}
+	return result
+}
+
+func (r *server) processChecks(context *gin.Context, handler checkHandler) {
+	allChecks, code, err := r.getChecks(context.Request.Context())
+	if err != nil {
+		r.respond(context, Verdict{StatusCode: code, Result: checks.Result{Status: checks.Failed, Detail: err.Error()}})
+		return
 	}
 	handler(allChecks)
 }
 
-func (r *server) getStatus(context *gin.Context) checks.HealthStatus {
+// getChecks gets all checks from Consul, or the cache.  If the checks could not be
+// retrieved, the status code to respond with is returned along with the error.
+func (r *server) getChecks(ctx context.Context) (*map[string]*checks.Check, int, error) {
+	target := fmt.Sprintf(consulChecksUrl, r.config().ConsulAddress)
+	_, span := r.tracer.Start(ctx, "cache lookup")
+	cachedChecks, ok := r.cache().Get(target)
+	span.SetAttributes(cacheHitAttribute.Bool(ok))
+	span.End()
+	recordCacheHit(ctx, ok)
+	if ok {
+		return cachedChecks.(*map[string]*checks.Check), 0, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, r.config().ConsulUnavailableStatusCode, err
+	}
+	resp, err := r.httpClient().Do(req)
+	if resp != nil && resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		r.logger.WithFields(logrus.Fields{"consul_address": r.config().ConsulAddress, "error": err}).Warn("Failed to query Consul")
+		return nil, r.config().ConsulUnavailableStatusCode, err
+	}
+	var allChecks *map[string]*checks.Check
+	err = r.jsonApi.NewDecoder(resp.Body).Decode(&allChecks)
+	if err != nil {
+		r.logger.WithFields(logrus.Fields{"consul_address": r.config().ConsulAddress, "consul_status": resp.StatusCode, "error": err}).Warn("Failed to decode Consul checks")
+		return nil, r.config().UnprocessableStatusCode, err
+	}
+	r.cache().Set(target, allChecks)
+	r.tracker.observe(allChecks, time.Now())
+	return allChecks, 0, nil
+}
+
+func (r *server) getStatus(context *gin.Context) (checks.HealthStatus, bool) {
 	status, statusSpecified := context.GetQuery(statusQueryStringKey)
 	if !statusSpecified {
-		return checks.HealthPassing
+		return checks.HealthPassing, true
 	}
 	parsedStatus, parsed := checks.ParseHealthStatus(status)
 	if !parsed {
-		r.abortWithStatusJSON(context, r.config.BadRequestStatusCode,
-			checks.Result{
-				Status: checks.Failed,
-				Detail: fmt.Sprintf("Unsupported status: %v", context.Query(statusQueryStringKey))})
+		r.respondBadRequest(context, fmt.Sprintf("Unsupported status: %v", context.Query(statusQueryStringKey)))
 	}
-	return parsedStatus
+	return parsedStatus, parsed
 }
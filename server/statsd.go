@@ -0,0 +1,130 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/statsd"
+	"github.com/pkg/errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func (r *server) createStatsD() error {
+	c := r.config().StatsDConfig
+	if c.Address != "" && c.Interval <= 0 {
+		return errors.Errorf("invalid statsd: unsupported interval: %v", c.Interval)
+	}
+	s, err := statsd.NewClient(r.config().StatsDConfig)
+	if err != nil {
+		return errors.Wrap(err, "invalid statsd")
+	}
+	r.statsd = s
+	if r.config().StatsDConfig.Address != "" {
+		r.notifiers = append(r.notifiers, &checkGauges{
+			statsd:   s,
+			interval: r.config().StatsDConfig.Interval,
+		})
+	}
+	return nil
+}
+
+// statsDMiddleware sends the count and latency of requests to StatsD.
+func (r *server) statsDMiddleware(context *gin.Context) {
+	start := time.Now()
+	context.Next()
+	tags := []string{
+		statsd.Tag("code", strconv.Itoa(context.Writer.Status())),
+		statsd.Tag("method", context.Request.Method),
+		statsd.Tag("url", metricsURL(context)),
+	}
+	r.statsd.Count("requests", 1, tags...)
+	r.statsd.Timing("request_duration", time.Since(start), tags...)
+}
+
+// checkGauges sends the number of checks of each service in each status to StatsD, whenever
+// they change and at the interval.
+type checkGauges struct {
+	statsd   statsd.Client
+	interval time.Duration
+	mu       sync.Mutex
+	counts   map[string]map[checks.HealthStatus]int
+}
+
+// notify counts the checks of each service.  A service which no longer has any checks is sent
+// once more with zero checks.  The counts are left alone while Consul is unavailable.
+func (g *checkGauges) notify(s *snapshot) {
+	if s.err != nil {
+		return
+	}
+	counts := make(map[string]map[checks.HealthStatus]int)
+	for _, check := range *s.allChecks {
+		status, ok := checks.ParseHealthStatus(check.Status)
+		if !ok {
+			continue
+		}
+		if counts[check.ServiceName] == nil {
+			counts[check.ServiceName] = make(map[checks.HealthStatus]int)
+		}
+		counts[check.ServiceName][status]++
+	}
+	g.mu.Lock()
+	removed := make(map[string]map[checks.HealthStatus]int)
+	for service := range g.counts {
+		if _, ok := counts[service]; !ok {
+			removed[service] = nil
+		}
+	}
+	g.counts = counts
+	g.mu.Unlock()
+	g.send(removed)
+	g.send(counts)
+}
+
+func (g *checkGauges) run(done <-chan struct{}) {
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			g.mu.Lock()
+			counts := g.counts
+			g.mu.Unlock()
+			g.send(counts)
+		case <-done:
+			return
+		}
+	}
+}
+
+func (g *checkGauges) send(counts map[string]map[checks.HealthStatus]int) {
+	services := make([]string, 0, len(counts))
+	for service := range counts {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		var tags []string
+		if service != "" {
+			tags = append(tags, statsd.Tag("service", service))
+		}
+		for status := checks.HealthPassing; status <= checks.HealthCritical; status++ {
+			g.statsd.Gauge("checks", float64(counts[service][status]), append(tags, statsd.Tag("status", status.String()))...)
+		}
+	}
+}
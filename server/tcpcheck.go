@@ -0,0 +1,184 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tcpCheckInitialBackoff is the initial backoff of listening again after it failed.
+	tcpCheckInitialBackoff = time.Second
+
+	// tcpCheckMaxBackoff is the maximum backoff of listening again after it failed.
+	tcpCheckMaxBackoff = 30 * time.Second
+)
+
+// tcpCheck listens for connections while its selector is healthy, accepting and immediately
+// closing them, and stops listening while it is unhealthy, so that connections are refused.  The
+// selector is healthy when its verify route would return the success status code.  The selector
+// is parsed for each snapshot, so reloaded profiles are used.  When listening fails while the
+// selector is healthy, it is retried with exponential backoff.
+type tcpCheck struct {
+	r        *server
+	address  string
+	name     string
+	mu       sync.Mutex
+	listener net.Listener
+	healthy  bool
+	stopped  bool
+	retry    chan struct{}
+}
+
+func (r *server) createTCPChecks() error {
+	for _, spec := range r.config().TCPCheckConfig.Listeners {
+		t, err := r.newTCPCheck(spec)
+		if err != nil {
+			return errors.Wrapf(err, "invalid TCP check listener %q", spec)
+		}
+		r.notifiers = append(r.notifiers, t)
+	}
+	return nil
+}
+
+func (r *server) newTCPCheck(spec string) (*tcpCheck, error) {
+	i := strings.Index(spec, "=")
+	if i < 0 {
+		return nil, errors.New("no selector")
+	}
+	if _, err := r.parseNamedSelector(spec[i+1:]); err != nil {
+		return nil, err
+	}
+	// The address is only listened on once the selector is healthy, so check it can be now.
+	listener, err := net.Listen("tcp", spec[:i])
+	if err != nil {
+		return nil, err
+	}
+	listener.Close()
+	return &tcpCheck{r: r, address: spec[:i], name: spec[i+1:], retry: make(chan struct{}, 1)}, nil
+}
+
+// notify starts or stops listening when the selector becomes healthy or unhealthy.
+func (t *tcpCheck) notify(s *snapshot) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped {
+		return
+	}
+	sel, err := t.r.parseNamedSelector(t.name)
+	if err != nil {
+		t.r.logger.WithFields(logrus.Fields{"address": t.address, "selector": t.name, "error": err}).Error("Invalid TCP check selector")
+		return
+	}
+	v := t.r.evaluateSnapshot(s, sel)
+	t.healthy = v.StatusCode == t.r.config().SuccessStatusCode
+	fields := logrus.Fields{"address": t.address, "selector": t.name, "verdict": v.Result.Status}
+	if t.healthy && t.listener == nil {
+		if err := t.listen(); err != nil {
+			t.r.logger.WithFields(fields).WithError(err).Error("Failed to accept TCP check connections, retrying")
+			select {
+			case t.retry <- struct{}{}:
+			default:
+			}
+			return
+		}
+		t.r.logger.WithFields(fields).Info("Accepting TCP check connections")
+	} else if !t.healthy && t.listener != nil {
+		t.listener.Close()
+		t.listener = nil
+		t.r.logger.WithFields(fields).Info("Refusing TCP check connections")
+	}
+}
+
+// listen starts accepting connections.  The caller must hold mu.
+func (t *tcpCheck) listen() error {
+	listener, err := net.Listen("tcp", t.address)
+	if err != nil {
+		return err
+	}
+	t.listener = listener
+	go accept(listener)
+	return nil
+}
+
+// relisten listens again after it failed, if the selector is still healthy, and returns whether
+// it needs to be retried.
+func (t *tcpCheck) relisten(backoff time.Duration) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopped || !t.healthy || t.listener != nil {
+		return false
+	}
+	fields := logrus.Fields{"address": t.address, "selector": t.name}
+	if err := t.listen(); err != nil {
+		t.r.logger.WithFields(fields).WithField("backoff", backoff.String()).WithError(err).Warn("Failed to accept TCP check connections, retrying")
+		return true
+	}
+	t.r.logger.WithFields(fields).Info("Accepting TCP check connections")
+	return false
+}
+
+// accept closes each connection as soon as it is accepted, until the listener is closed.
+func accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}
+}
+
+// run retries listening with exponential backoff after it failed, until done is closed.
+func (t *tcpCheck) run(done <-chan struct{}) {
+	var backoff time.Duration
+	var retry <-chan time.Time
+	for {
+		select {
+		case <-t.retry:
+			if retry == nil {
+				backoff = tcpCheckInitialBackoff
+				retry = time.After(backoff)
+			}
+		case <-retry:
+			retry = nil
+			backoff *= 2
+			if backoff > tcpCheckMaxBackoff {
+				backoff = tcpCheckMaxBackoff
+			}
+			if t.relisten(backoff) {
+				retry = time.After(backoff)
+			}
+		case <-done:
+			t.stop()
+			return
+		}
+	}
+}
+
+func (t *tcpCheck) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	if t.listener != nil {
+		t.listener.Close()
+		t.listener = nil
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// certificate serves the TLS certificate, reloading it when the certificate or key file
+// changes.  New connections use the reloaded certificate, while existing connections are left
+// alone.
+type certificate struct {
+	r        *server
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	current  *tls.Certificate
+	version  string
+	expiry   prometheus.Gauge
+	reloads  *prometheus.CounterVec
+	done     chan struct{}
+}
+
+// createTLS configures the server to serve HTTPS, if a certificate and key file are configured.
+func (r *server) createTLS() error {
+	c := r.config().TLSConfig
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAFile != "" {
+			return errors.New("invalid TLS: a client CA file requires a certificate and a key file")
+		}
+		return nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return errors.New("invalid TLS: both a certificate and a key file are required")
+	}
+	minVersion, ok := tlsVersions[c.MinVersion]
+	if !ok {
+		return errors.Errorf("invalid TLS: unsupported minimum version: %s", c.MinVersion)
+	}
+	cipherSuites, err := parseCipherSuites(c.CipherSuites)
+	if err != nil {
+		return errors.Wrap(err, "invalid TLS")
+	}
+	if c.ReloadInterval <= 0 {
+		return errors.Errorf("invalid TLS: unsupported reload interval: %v", c.ReloadInterval)
+	}
+	cert := &certificate{
+		r:        r,
+		certFile: c.CertFile,
+		keyFile:  c.KeyFile,
+		done:     make(chan struct{}),
+		expiry: register(prometheus.NewGauge(prometheus.GaugeOpts{
+			Subsystem: "consulate",
+			Name:      "tls_certificate_expiry_timestamp_seconds",
+			Help:      "The time at which the TLS certificate expires, in seconds since the epoch.",
+		})).(prometheus.Gauge),
+		reloads: register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Subsystem: "consulate",
+			Name:      "tls_certificate_reloads_total",
+			Help:      "Total number of times the TLS certificate was reloaded.",
+		}, []string{"result"})).(*prometheus.CounterVec),
+	}
+	if err := cert.load(); err != nil {
+		return errors.Wrap(err, "invalid TLS")
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: cert.get,
+	}
+	if err := r.configureClientCA(tlsConfig); err != nil {
+		return errors.Wrap(err, "invalid TLS")
+	}
+	r.certificate = cert
+	for _, s := range r.httpServers() {
+		s.TLSConfig = tlsConfig
+	}
+	go cert.watch(c.ReloadInterval)
+	return nil
+}
+
+// parseCipherSuites gets the IDs of the named cipher suites, which must be secure.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	supported := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		supported[s.Name] = s.ID
+	}
+	var ids []uint16
+	for _, name := range names {
+		id, ok := supported[name]
+		if !ok {
+			return nil, errors.Errorf("unsupported cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (r *server) stopTLS() {
+	if r.certificate != nil {
+		close(r.certificate.done)
+		r.certificate = nil
+	}
+}
+
+func (c *certificate) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current, nil
+}
+
+// fileVersion identifies the contents of the files by their modification times and sizes.
+func fileVersion(paths ...string) (string, error) {
+	var version string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		version += fmt.Sprintf("%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return version, nil
+}
+
+func (c *certificate) load() error {
+	version, err := fileVersion(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.version = version
+	pair, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.current = &pair
+	c.mu.Unlock()
+	c.expiry.Set(float64(pair.Leaf.NotAfter.Unix()))
+	c.r.logger.WithFields(logrus.Fields{"cert_file": c.certFile, "subject": pair.Leaf.Subject.String(), "not_after": pair.Leaf.NotAfter}).Info("Loaded TLS certificate")
+	return nil
+}
+
+// watch reloads the certificate whenever the files change.  When the certificate cannot be
+// loaded, like when only one of the files has been replaced, the previous certificate is served
+// until the files change again.
+func (c *certificate) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+		if version, err := fileVersion(c.certFile, c.keyFile); err == nil && version == c.version {
+			continue
+		}
+		if err := c.load(); err != nil {
+			c.reloads.WithLabelValues("failure").Inc()
+			c.r.logger.WithFields(logrus.Fields{"cert_file": c.certFile, "key_file": c.keyFile, "error": err}).Error("Failed to reload TLS certificate")
+			continue
+		}
+		c.reloads.WithLabelValues("success").Inc()
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/version"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName  = "github.com/kadaan/consulate"
+	serviceName = "consulate"
+
+	cacheHitAttribute      = attribute.Key("consulate.cache.hit")
+	verdictAttribute       = attribute.Key("consulate.verdict")
+	statusCodeAttribute    = attribute.Key("consulate.status_code")
+	matchedChecksAttribute = attribute.Key("consulate.checks.matched")
+	passingChecksAttribute = attribute.Key("consulate.checks.passing")
+	warningChecksAttribute = attribute.Key("consulate.checks.warning")
+	failingChecksAttribute = attribute.Key("consulate.checks.failing")
+)
+
+// createTracing creates the tracer, which does nothing when no endpoint is configured.
+func (r *server) createTracing() error {
+	r.propagator = propagation.TraceContext{}
+	c := r.config().TracingConfig
+	if c.Endpoint == "" {
+		r.tracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+		return nil
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return errors.Errorf("invalid tracing: unsupported sample ratio: %v", c.SampleRatio)
+	}
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(c.Endpoint)}
+	if c.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return errors.Wrap(err, "invalid tracing")
+	}
+	r.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(version.Version))),
+	)
+	r.tracer = r.tracerProvider.Tracer(tracerName)
+	return nil
+}
+
+// stopTracing exports the remaining spans.
+func (r *server) stopTracing(ctx context.Context) {
+	if r.tracerProvider == nil {
+		return
+	}
+	if err := r.tracerProvider.Shutdown(ctx); err != nil {
+		r.logger.WithError(err).Warn("Failed to export traces")
+	}
+}
+
+// tracingMiddleware traces requests, continuing the trace context of the request.
+func (r *server) tracingMiddleware(context *gin.Context) {
+	route := context.FullPath()
+	name := route
+	if name == "" {
+		name = "HTTP " + context.Request.Method
+	}
+	ctx := r.propagator.Extract(context.Request.Context(), propagation.HeaderCarrier(context.Request.Header))
+	ctx, span := r.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serviceName, route, context.Request)...))
+	defer span.End()
+	context.Request = context.Request.WithContext(ctx)
+	context.Next()
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(context.Writer.Status())...)
+}
+
+// traceEvaluation traces the evaluation of the checks, recording its verdict.
+func (r *server) traceEvaluation(ctx context.Context, evaluate func() Verdict) Verdict {
+	_, span := r.tracer.Start(ctx, "evaluate")
+	defer span.End()
+	v := evaluate()
+	span.SetAttributes(
+		verdictAttribute.String(string(v.Result.Status)),
+		statusCodeAttribute.Int(v.StatusCode),
+		matchedChecksAttribute.Int(len(v.Statuses)),
+		passingChecksAttribute.Int(v.Counts[checks.StatusPassing]),
+		warningChecksAttribute.Int(v.Counts[checks.StatusWarning]),
+		failingChecksAttribute.Int(v.Counts[checks.StatusFailing]))
+	return v
+}
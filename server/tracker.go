@@ -0,0 +1,69 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/checks"
+	"sync"
+	"time"
+)
+
+type statusChange struct {
+	status string
+	since  time.Time
+}
+
+// statusTracker records when Consulate first observed the current status of each check.
+type statusTracker struct {
+	mu       sync.Mutex
+	changes  map[string]statusChange
+	observed time.Time
+}
+
+func newStatusTracker() *statusTracker {
+	return &statusTracker{changes: make(map[string]statusChange)}
+}
+
+func (t *statusTracker) observe(allChecks *map[string]*checks.Check, now time.Time) {
+	if allChecks == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observed = now
+	for k, v := range *allChecks {
+		if c, ok := t.changes[k]; !ok || c.status != v.Status {
+			t.changes[k] = statusChange{status: v.Status, since: now}
+		}
+	}
+	for k := range t.changes {
+		if _, ok := (*allChecks)[k]; !ok {
+			delete(t.changes, k)
+		}
+	}
+}
+
+func (t *statusTracker) since(checkID string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.changes[checkID]
+	return c.since, ok
+}
+
+func (t *statusTracker) lastObserved() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.observed
+}
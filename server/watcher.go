@@ -0,0 +1,162 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"github.com/kadaan/consulate/checks"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// watcherHistorySize is the number of recent snapshots retained, so that watchers which fall
+// behind, or reconnect, can catch up on the changes they missed.
+const watcherHistorySize = 100
+
+type fetchChecks func() (*map[string]*checks.Check, int, error)
+
+// snapshot is the state of the Consul checks at a point in time.  If the checks could not be
+// retrieved, code and err describe why.
+type snapshot struct {
+	index     uint64
+	allChecks *map[string]*checks.Check
+	code      int
+	err       error
+}
+
+// watcher polls Consul in the background and publishes a new snapshot whenever the checks
+// change.  Polling begins when the first snapshot is requested, so Consul is not polled unless
+// something is watching.
+type watcher struct {
+	mu       sync.Mutex
+	fetch    fetchChecks
+	interval time.Duration
+	current  *snapshot
+	history  []*snapshot
+	hash     uint64
+	changed  chan struct{}
+	done     chan struct{}
+	start    sync.Once
+	stop     sync.Once
+}
+
+func newWatcher(fetch fetchChecks, interval time.Duration) *watcher {
+	return &watcher{
+		fetch:    fetch,
+		interval: interval,
+		changed:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// latest gets the current snapshot and a channel which is closed once it has been superseded.
+func (w *watcher) latest() (*snapshot, <-chan struct{}) {
+	w.start.Do(func() {
+		w.poll()
+		go w.run()
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current, w.changed
+}
+
+// at gets the retained snapshot with the specified index.
+func (w *watcher) at(index uint64) (*snapshot, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, s := range w.history {
+		if s.index == index {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// after gets the retained snapshots newer than the specified index, oldest first.
+func (w *watcher) after(index uint64) []*snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var result []*snapshot
+	for _, s := range w.history {
+		if s.index > index {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// stopped gets a channel which is closed once the watcher has been closed.
+func (w *watcher) stopped() <-chan struct{} {
+	return w.done
+}
+
+func (w *watcher) close() {
+	w.stop.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *watcher) run() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+func (w *watcher) poll() {
+	allChecks, code, err := w.fetch()
+	h := fnv.New64a()
+	if err != nil {
+		h.Write([]byte(err.Error()))
+	} else {
+		b, _ := json.Marshal(allChecks)
+		h.Write(b)
+	}
+	sum := h.Sum64()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current != nil && w.hash == sum {
+		return
+	}
+	var index uint64 = 1
+	if w.current != nil {
+		index = w.current.index + 1
+	}
+	w.current = &snapshot{index: index, allChecks: allChecks, code: code, err: err}
+	w.hash = sum
+	w.history = append(w.history, w.current)
+	if len(w.history) > watcherHistorySize {
+		w.history = w.history[len(w.history)-watcherHistorySize:]
+	}
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// evaluateSnapshot verifies the checks selected by the selector in the snapshot.  When Consul
+// could not be queried, the selector fails with the error.
+func (r *server) evaluateSnapshot(s *snapshot, sel *selector) Verdict {
+	if s.err != nil {
+		return Verdict{StatusCode: s.code, Result: checks.Result{Status: checks.Failed, Detail: s.err.Error()}}
+	}
+	return r.evaluate(s.allChecks, sel.matcher, sel.status, sel.verbose)
+}
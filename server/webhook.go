@@ -0,0 +1,284 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// WebhookEvent is sent by webhooks when the status of a selector changes.  It is the data of
+// the body template, and the body when no template is configured.
+type WebhookEvent struct {
+	Webhook   string
+	Selector  string
+	OldStatus checks.ResultStatus
+	NewStatus checks.ResultStatus
+	Detail    string                   `json:",omitempty"`
+	Counts    map[checks.Status]int    `json:",omitempty"`
+	Checks    map[string]*checks.Check `json:",omitempty"`
+	Time      time.Time
+}
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhook sends a WebhookEvent whenever the status of one of its selectors changes.  Events
+// are queued and retried with backoff, and an event which has not been sent yet is replaced by
+// a newer event for the same selector, or dropped when the selector has changed back.
+type webhook struct {
+	r       *server
+	name    string
+	config  config.WebhookConfig
+	body    *template.Template
+	client  *http.Client
+	states  map[string]checks.ResultStatus
+	mu      sync.Mutex
+	pending []*WebhookEvent
+	wake    chan struct{}
+}
+
+func (r *server) createWebhooks() error {
+	for _, c := range r.config().Webhooks {
+		w, err := r.newWebhook(c)
+		if err != nil {
+			return err
+		}
+		r.notifiers = append(r.notifiers, w)
+	}
+	return nil
+}
+
+func (r *server) newWebhook(c config.WebhookConfig) (*webhook, error) {
+	w := &webhook{
+		r:      r,
+		name:   c.Name,
+		config: c,
+		client: createNotifierClient(&r.config().ClientConfig),
+		states: make(map[string]checks.ResultStatus),
+		wake:   make(chan struct{}, 1),
+	}
+	if w.name == "" {
+		w.name = c.URL
+	}
+	if c.URL == "" {
+		return nil, errors.Errorf("invalid webhook %q: url is required", w.name)
+	}
+	if c.Body != "" {
+		body, err := template.New(w.name).Funcs(webhookFuncs).Parse(c.Body)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid webhook %q", w.name)
+		}
+		w.body = body
+	}
+	if _, err := r.namedSelectors(c.Selectors, c.Profiles); err != nil {
+		return nil, errors.Wrapf(err, "invalid webhook %q", w.name)
+	}
+	return w, nil
+}
+
+// namedSelectors parses the verify routes and looks up the profiles, which are named
+// profile/<name>.  When neither are specified, every profile is selected.
+func (r *server) namedSelectors(routes []string, profiles []string) (map[string]*selector, error) {
+	selectors := make(map[string]*selector)
+	for _, route := range routes {
+		sel, err := parseSelector(route)
+		if err != nil {
+			return nil, err
+		}
+		selectors[route] = sel
+	}
+	for _, name := range profiles {
+		sel, ok := r.profiles()[name]
+		if !ok {
+			return nil, errors.Errorf("unknown profile: %s", name)
+		}
+		selectors[profileCheckNamePrefix+name] = sel
+	}
+	if len(routes) == 0 && len(profiles) == 0 {
+		for name, sel := range r.profiles() {
+			selectors[profileCheckNamePrefix+name] = sel
+		}
+	}
+	if len(selectors) == 0 {
+		return nil, errors.New("no selectors or profiles")
+	}
+	return selectors, nil
+}
+
+// selectorNames gets the sorted names of the selectors.
+func selectorNames(selectors map[string]*selector) []string {
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// forgetStates deletes the states of the selectors which are no longer selected, like removed
+// profiles, so they are recorded again without an event when they are selected again.
+func forgetStates(states map[string]checks.ResultStatus, selectors map[string]*selector) {
+	for name := range states {
+		if _, ok := selectors[name]; !ok {
+			delete(states, name)
+		}
+	}
+}
+
+// notify queues an event for each selector whose status changed since the previous snapshot.
+// The first snapshot only records the status of each selector.  The profiles are looked up for
+// each snapshot, so reloaded profiles are used.
+func (w *webhook) notify(s *snapshot) {
+	selectors, err := w.r.namedSelectors(w.config.Selectors, w.config.Profiles)
+	if err != nil {
+		w.r.logger.WithFields(logrus.Fields{"webhook": w.name, "error": err}).Error("Invalid webhook selectors")
+		return
+	}
+	forgetStates(w.states, selectors)
+	now := time.Now()
+	for _, name := range selectorNames(selectors) {
+		v := w.r.evaluateSnapshot(s, selectors[name])
+		old, seen := w.states[name]
+		w.states[name] = v.Result.Status
+		if !seen || old == v.Result.Status {
+			continue
+		}
+		w.enqueue(&WebhookEvent{
+			Webhook:   w.name,
+			Selector:  name,
+			OldStatus: old,
+			NewStatus: v.Result.Status,
+			Detail:    v.Result.Detail,
+			Counts:    v.Result.Counts,
+			Checks:    withoutDefinitions(v.Result.Checks),
+			Time:      now,
+		})
+	}
+}
+
+func (w *webhook) enqueue(e *WebhookEvent) {
+	w.mu.Lock()
+	for i, p := range w.pending {
+		if p.Selector == e.Selector {
+			e.OldStatus = p.OldStatus
+			w.pending = append(w.pending[:i], w.pending[i+1:]...)
+			break
+		}
+	}
+	if e.OldStatus != e.NewStatus {
+		w.pending = append(w.pending, e)
+	}
+	w.mu.Unlock()
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *webhook) next() *WebhookEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	e := w.pending[0]
+	w.pending = w.pending[1:]
+	return e
+}
+
+func (w *webhook) run(done <-chan struct{}) {
+	for {
+		e := w.next()
+		if e == nil {
+			select {
+			case <-w.wake:
+				continue
+			case <-done:
+				return
+			}
+		}
+		w.deliver(e, done)
+	}
+}
+
+// deliver sends the event, retrying with exponential backoff until it is sent, the retries
+// are exhausted, or the server stops.
+func (w *webhook) deliver(e *WebhookEvent, done <-chan struct{}) {
+	backoff := w.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.send(e)
+		if err == nil {
+			return
+		}
+		if attempt >= w.config.MaxRetries {
+			w.r.logger.WithFields(logrus.Fields{"webhook": w.name, "selector": e.Selector, "attempts": attempt + 1, "error": err}).Error("Failed to send webhook")
+			return
+		}
+		w.r.logger.WithFields(logrus.Fields{"webhook": w.name, "selector": e.Selector, "backoff": backoff.String(), "error": err}).Warn("Failed to send webhook, retrying")
+		select {
+		case <-time.After(backoff):
+		case <-done:
+			return
+		}
+		backoff *= 2
+		if backoff > w.config.MaxBackoff {
+			backoff = w.config.MaxBackoff
+		}
+	}
+}
+
+func (w *webhook) send(e *WebhookEvent) error {
+	var body bytes.Buffer
+	if w.body != nil {
+		if err := w.body.Execute(&body, e); err != nil {
+			return errors.Wrap(err, "failed to render body")
+		}
+	} else if err := json.NewEncoder(&body).Encode(e); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(w.config.Method, w.config.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", gin.MIMEJSON)
+	for k, v := range w.config.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookEnqueue(t *testing.T) {
+	w := &webhook{wake: make(chan struct{}, 1)}
+	w.enqueue(&WebhookEvent{Selector: "a", OldStatus: checks.Ok, NewStatus: checks.Warning})
+	w.enqueue(&WebhookEvent{Selector: "b", OldStatus: checks.Ok, NewStatus: checks.Failed})
+	w.enqueue(&WebhookEvent{Selector: "a", OldStatus: checks.Warning, NewStatus: checks.Failed})
+	w.enqueue(&WebhookEvent{Selector: "b", OldStatus: checks.Failed, NewStatus: checks.Ok})
+
+	e := w.next()
+	if e == nil || e.Selector != "a" || e.OldStatus != checks.Ok || e.NewStatus != checks.Failed {
+		t.Errorf("want merged event a Ok->Failed, got %+v", e)
+	}
+	if e = w.next(); e != nil {
+		t.Errorf("want no more events, got %+v", e)
+	}
+}
+
+func TestWebhookClient(t *testing.T) {
+	c := config.DefaultServerConfig()
+	c.ClientConfig.QueryTimeout = 7 * time.Second
+	w, err := newServer(c).newWebhook(config.WebhookConfig{URL: "http://hooks/", Selectors: []string{"/verify/checks"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.client.Transport.(*http.Transport); !ok {
+		t.Errorf("Transport: want *http.Transport, got %T", w.client.Transport)
+	}
+	if w.client.Timeout != c.ClientConfig.QueryTimeout {
+		t.Errorf("Timeout: want %v, got %v", c.ClientConfig.QueryTimeout, w.client.Timeout)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccessControl(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.AccessConfig.TrustedProxies = []string{"127.0.0.1"}
+		c.AccessConfig.Allow = []string{"verbose=10.0.0.0/8", "metrics=10.0.0.0/8,127.0.0.1"}
+		c.AccessConfig.Deny = []string{"verify=192.0.2.0/24", "verbose=10.1.0.0/16"}
+	})
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	for _, d := range []struct {
+		path      string
+		forwarded string
+		expected  int
+	}{
+		{"/verify/service/id/service2", "", http.StatusOK},
+		{"/verify/service/id/service2", "198.51.100.1", http.StatusOK},
+		{"/verify/service/id/service2", "192.0.2.1", http.StatusForbidden},
+		{"/verify/service/id/service2", "192.0.2.1, 198.51.100.1", http.StatusOK},
+		{"/verify/service/id/service2?verbose", "", http.StatusForbidden},
+		{"/verify/service/id/service2?verbose", "10.2.0.1", http.StatusOK},
+		{"/verify/service/id/service2?verbose", "10.1.0.1", http.StatusForbidden},
+		{"/verify/service/id/service2?format=health", "", http.StatusForbidden},
+		{"/verify/service/id/service2?format=html", "10.1.0.1", http.StatusForbidden},
+		{"/verify/service/id/service2?format=health", "10.2.0.1", http.StatusOK},
+		{"/verify/service/id/service2?format=json", "10.1.0.1", http.StatusOK},
+		{"/ui/", "10.2.0.1", http.StatusOK},
+		{"/ui/", "198.51.100.1", http.StatusForbidden},
+		{"/metrics", "198.51.100.1", http.StatusForbidden},
+		{"/history", "198.51.100.1", http.StatusNotFound},
+	} {
+		if code := forwardedGet(t, server, d.path, d.forwarded).StatusCode; code != d.expected {
+			t.Errorf("%s from %q: want %d, got %d", d.path, d.forwarded, d.expected, code)
+		}
+	}
+
+	body, _ := ioutil.ReadAll(forwardedGet(t, server, "/metrics", "").Body)
+	for _, expected := range []string{
+		`consulate_access_requests_total{group="verify",result="allowed"}`,
+		`consulate_access_requests_total{group="verify",result="denied"}`,
+		`consulate_access_requests_total{group="verbose",result="denied"}`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Metrics do not contain %s", expected)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.RateLimitedStatusCode = 498
+		c.AccessConfig.TrustedProxies = []string{"127.0.0.1"}
+		c.RateLimitConfig.Rate = 0.01
+		c.RateLimitConfig.Burst = 2
+	})
+	defer server.Stop()
+
+	for _, d := range []struct {
+		forwarded string
+		expected  int
+	}{
+		{"198.51.100.1", http.StatusOK},
+		{"198.51.100.1", http.StatusOK},
+		{"198.51.100.1", 498},
+		{"198.51.100.2", http.StatusOK},
+		{"198.51.100.2, 198.51.100.1", 498},
+		{"198.51.100.2", http.StatusOK},
+		{"198.51.100.2", 498},
+	} {
+		resp := forwardedGet(t, server, "/about", d.forwarded)
+		if resp.StatusCode != d.expected {
+			t.Errorf("Request from %q: want %d, got %d", d.forwarded, d.expected, resp.StatusCode)
+		}
+		if resp.StatusCode == 498 && resp.Header.Get("Retry-After") != "100" {
+			t.Errorf("Retry-After: want 100, got %q", resp.Header.Get("Retry-After"))
+		}
+	}
+
+	body, _ := ioutil.ReadAll(forwardedGet(t, server, "/metrics", "").Body)
+	for _, expected := range []string{
+		`consulate_rate_limit_requests_total{group="verify",result="allowed"}`,
+		`consulate_rate_limit_requests_total{group="verify",result="limited"}`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Metrics do not contain %s", expected)
+		}
+	}
+}
+
+func TestRateLimitByIdentity(t *testing.T) {
+	server := newServerWithConfig(t, func(c *config.ServerConfig) {
+		c.AuthConfig.Rules = []string{"verbose=bearer:first", "verbose=bearer:second"}
+		c.RateLimitConfig.Rate = 0.01
+		c.RateLimitConfig.Burst = 1
+		c.RateLimitConfig.Key = "identity"
+	})
+	defer server.Stop()
+
+	// The IP was limited by the request to /livez which waited for the server to start, while
+	// authenticated requests are limited by their identity, and failed authentication by IP.
+	for _, d := range []struct {
+		request  authRequest
+		expected int
+	}{
+		{authRequest{path: "/about"}, http.StatusTooManyRequests},
+		{authRequest{path: "/ui/", token: "first"}, http.StatusOK},
+		{authRequest{path: "/ui/", token: "first"}, http.StatusTooManyRequests},
+		{authRequest{path: "/ui/", token: "second"}, http.StatusOK},
+		{authRequest{path: "/ui/", token: "guess"}, http.StatusUnauthorized},
+		{authRequest{path: "/ui/", token: "guess"}, http.StatusTooManyRequests},
+		{authRequest{path: "/ui/"}, http.StatusTooManyRequests},
+	} {
+		if code := authGet(t, server, d.request).StatusCode; code != d.expected {
+			t.Errorf("%+v: want %d, got %d", d.request, d.expected, code)
+		}
+	}
+}
+
+func TestInvalidAccessControl(t *testing.T) {
+	for expected, configure := range map[string]func(c *config.ServerConfig){
+		`invalid access rule "verify=10.0.0.256": unsupported CIDR: 10.0.0.256`: func(c *config.ServerConfig) {
+			c.AccessConfig.Deny = []string{"verify=10.0.0.256"}
+		},
+		"invalid trusted proxies: unsupported CIDR: proxy": func(c *config.ServerConfig) {
+			c.AccessConfig.TrustedProxies = []string{"proxy"}
+		},
+		"invalid rate limit: unsupported rate: -1": func(c *config.ServerConfig) {
+			c.RateLimitConfig.Rate = -1
+		},
+		"invalid rate limit: unsupported burst: 0": func(c *config.ServerConfig) {
+			c.RateLimitConfig.Rate, c.RateLimitConfig.Burst = 1, 0
+		},
+		"invalid rate limit: unsupported key: user": func(c *config.ServerConfig) {
+			c.RateLimitConfig.Rate, c.RateLimitConfig.Key = 1, "user"
+		},
+	} {
+		server, err := testutil.NewTestServerWithConfig(t, configure)
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with invalid access control: %s", expected)
+		}
+		if err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
+
+// forwardedGet requests the path with the X-Forwarded-For header, when it is not empty,
+// returning the response with its body read.
+func forwardedGet(t *testing.T, server *testutil.WrappedTestServer, path string, forwarded string) *http.Response {
+	req, err := http.NewRequest("GET", server.Url(path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forwarded != "" {
+		req.Header.Set("X-Forwarded-For", forwarded)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	return resp
+}
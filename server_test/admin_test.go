@@ -0,0 +1,130 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminListener(t *testing.T) {
+	adminAddr := fmt.Sprintf("127.0.0.1:%v", freeport.GetT(t, 1)[0])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.AdminListenAddress = adminAddr
+		c.AuthConfig.Rules = []string{"verbose=bearer:secret"}
+	})
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	admin := func(path string) string {
+		return "http://" + adminAddr + path
+	}
+	for _, d := range []struct {
+		url      string
+		expected int
+	}{
+		{server.Url("/verify/service/id/service2"), http.StatusOK},
+		{server.Url("/livez"), http.StatusOK},
+		{server.Url("/metrics"), http.StatusNotFound},
+		{server.Url("/about"), http.StatusNotFound},
+		{server.Url("/history"), http.StatusNotFound},
+		{server.Url("/config"), http.StatusNotFound},
+		{server.Url("/debug/pprof/"), http.StatusNotFound},
+		{server.Url("/openapi.json"), http.StatusOK},
+		{admin("/verify/service/id/service2"), http.StatusNotFound},
+		{admin("/livez"), http.StatusNotFound},
+		{admin("/metrics"), http.StatusOK},
+		{admin("/about"), http.StatusOK},
+		{admin("/history"), http.StatusNotFound},
+		{admin("/debug/pprof/"), http.StatusOK},
+		{admin("/debug/pprof/cmdline"), http.StatusOK},
+		{admin("/debug/pprof/goroutine?debug=1"), http.StatusOK},
+		{admin("/openapi.json"), http.StatusOK},
+	} {
+		resp, err := server.Client().Get(d.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != d.expected {
+			t.Errorf("%s: want %d, got %d", d.url, d.expected, resp.StatusCode)
+		}
+	}
+
+	resp, err := server.Client().Get(admin("/config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Config status code: want 200, got %d", resp.StatusCode)
+	}
+	for _, expected := range []string{`"AdminListenAddress":"` + adminAddr + `"`, `"verbose=bearer:xxxxx"`} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Config does not contain %s", expected)
+		}
+	}
+	if strings.Contains(string(body), "secret") {
+		t.Error("Config contains the bearer token")
+	}
+}
+
+func TestCacheFlush(t *testing.T) {
+	adminAddr := fmt.Sprintf("127.0.0.1:%v", freeport.GetT(t, 1)[0])
+	server := newServerWithConfig(t, func(c *config.ServerConfig) {
+		c.AdminListenAddress = adminAddr
+		c.CacheConfig.ConsulCacheDuration = time.Hour
+	})
+	defer server.Stop()
+
+	verifyGetApiCall(t, server, "/verify/service/id/service1", http.StatusNotFound, `{"Status":"No Checks","Detail":"No checks for services with ServiceId: service1"}`)
+	server.AddService("service1", []string{})
+	server.AddCheck("check1a", "check 1", "service1", checks.HealthPassing, "Passing check")
+	verifyGetApiCall(t, server, "/verify/service/id/service1", http.StatusNotFound, `{"Status":"No Checks","Detail":"No checks for services with ServiceId: service1"}`)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		resp, err := server.Client().Post("http://"+adminAddr+"/cache/flush", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != `{"Status":"Ok","Detail":"Flushed the Consul cache"}` {
+			t.Fatalf("Cache flush: got %d %s", resp.StatusCode, body)
+		}
+		resp, err = server.Client().Get(server.Url("/verify/service/id/service1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("Status code after the cache was flushed: want 200, got %d", resp.StatusCode)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAgentCheck(t *testing.T) {
+	ports := freeport.GetT(t, 2)
+	configured := fmt.Sprintf("127.0.0.1:%v", ports[0])
+	sent := fmt.Sprintf("127.0.0.1:%v", ports[1])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.Profiles = map[string]string{"service3": "/verify/service/id/service3"}
+		c.AgentCheckConfig.Listeners = []string{configured + "=service2", sent}
+		c.AgentCheckConfig.WarningWeight = 25
+	})
+	defer server.Stop()
+
+	waitForAgentCheck(t, configured, "", "up ready 100%")
+	for _, d := range []struct {
+		address  string
+		selector string
+		expected string
+	}{
+		{configured, "", "up ready 100%"},
+		{sent, "service2", "up ready 100%"},
+		{sent, "/verify/service/id/service1?status=warning", "down"},
+		{sent, "profile/service3", "up ready 25%"},
+		{sent, "service4", "down"},
+		{sent, "profile/unknown", "fail"},
+	} {
+		if reply := agentCheck(t, d.address, d.selector); reply != d.expected {
+			t.Errorf("Agent check %q: want %q, got %q", d.selector, d.expected, reply)
+		}
+	}
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthWarning, "Warning check")
+	waitForAgentCheck(t, configured, "", "up ready 25%")
+	server.AddCheck("_service_maintenance:service2", "Service Maintenance Mode", "service2", checks.HealthCritical, "Maintenance")
+	waitForAgentCheck(t, configured, "", "maint")
+	if reply := agentCheck(t, sent, "service1"); reply != "down" {
+		t.Errorf("Agent check %q: want %q, got %q", "service1", "down", reply)
+	}
+}
+
+func TestInvalidAgentCheck(t *testing.T) {
+	server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+		c.AgentCheckConfig.Listeners = []string{"127.0.0.1:0=profile/unknown"}
+	})
+	if err == nil {
+		server.Stop()
+		t.Fatal("Server started with an invalid agent check listener")
+	}
+	expected := `invalid agent check listener "127.0.0.1:0=profile/unknown": unknown profile: unknown`
+	if err.Error() != expected {
+		t.Errorf("Error: %q, want %q", err.Error(), expected)
+	}
+}
+
+func agentCheck(t *testing.T, address string, selector string) string {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		t.Fatalf("Failed to connect to agent check listener: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if selector != "" {
+		if _, err := fmt.Fprintf(conn, "%s\n", selector); err != nil {
+			t.Fatalf("Failed to send selector: %v", err)
+		}
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read agent check reply: %v", err)
+	}
+	return strings.TrimSuffix(reply, "\n")
+}
+
+func waitForAgentCheck(t *testing.T, address string, selector string, expected string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		reply := agentCheck(t, address, selector)
+		if reply == expected {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("Agent check %q: want %q, got %q", selector, expected, reply)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
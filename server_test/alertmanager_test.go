@@ -0,0 +1,124 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type postedAlert struct {
+	Labels      map[string]string
+	Annotations map[string]string
+	StartsAt    time.Time
+	EndsAt      time.Time
+}
+
+type fakeAlertmanager struct {
+	*httptest.Server
+	alerts chan postedAlert
+}
+
+func newFakeAlertmanager(t *testing.T) *fakeAlertmanager {
+	f := &fakeAlertmanager{alerts: make(chan postedAlert, 1000)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v2/alerts" {
+			t.Errorf("Unexpected request: %s %s", req.Method, req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var alerts []postedAlert
+		if err := json.NewDecoder(req.Body).Decode(&alerts); err != nil {
+			t.Errorf("Failed to decode alerts: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, a := range alerts {
+			f.alerts <- a
+		}
+	}))
+	return f
+}
+
+// next waits for an alert of the check which is firing, or resolved.
+func (f *fakeAlertmanager) next(t *testing.T, checkID string, firing bool) postedAlert {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case a := <-f.alerts:
+			if a.Labels["check_id"] == checkID && a.EndsAt.After(time.Now()) == firing {
+				return a
+			}
+		case <-timeout:
+			t.Fatalf("Alert for %s was not sent, firing: %v", checkID, firing)
+			return postedAlert{}
+		}
+	}
+}
+
+func TestAlertmanager(t *testing.T) {
+	alertmanager := newFakeAlertmanager(t)
+	defer alertmanager.Close()
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"service2": "/verify/service/id/service2"}
+		c.AlertmanagerConfig.URL = alertmanager.URL + "/"
+		c.AlertmanagerConfig.ResendInterval = 50 * time.Millisecond
+	})
+	defer server.Stop()
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	firing := alertmanager.next(t, "check2a", true)
+	for firing.Annotations["description"] == "" {
+		// Registering the check makes it critical before its output is updated.
+		firing = alertmanager.next(t, "check2a", true)
+	}
+	expected := map[string]string{
+		"alertname":  "ConsulCheck",
+		"severity":   "critical",
+		"profile":    "service2",
+		"node":       server.GetConsulNodeName(),
+		"check":      "check 2",
+		"check_id":   "check2a",
+		"service":    "service2",
+		"service_id": "service2",
+	}
+	if len(firing.Labels) != len(expected) {
+		t.Errorf("Labels: want %v, got %v", expected, firing.Labels)
+	}
+	for k, v := range expected {
+		if firing.Labels[k] != v {
+			t.Errorf("Label %s: want %s, got %s", k, v, firing.Labels[k])
+		}
+	}
+	if firing.Annotations["description"] != "Critical check" {
+		t.Errorf("Description: want Critical check, got %s", firing.Annotations["description"])
+	}
+	if resent := alertmanager.next(t, "check2a", true); !resent.StartsAt.Equal(firing.StartsAt) {
+		t.Errorf("Resent alert StartsAt: want %v, got %v", firing.StartsAt, resent.StartsAt)
+	}
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+	if resolved := alertmanager.next(t, "check2a", false); !resolved.StartsAt.Equal(firing.StartsAt) {
+		t.Errorf("Resolved alert StartsAt: want %v, got %v", firing.StartsAt, resolved.StartsAt)
+	}
+}
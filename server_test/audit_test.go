@@ -0,0 +1,148 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/json"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/server"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	var check, profile *server.Transition
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"service2": "/verify/service/id/service2"}
+		c.AuditConfig.Path = path
+	})
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), OK)
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now().UTC().Format(time.RFC3339Nano)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+
+	timeout := time.After(5 * time.Second)
+	for check == nil || profile == nil {
+		select {
+		case <-timeout:
+			t.Fatalf("Transitions were not recorded: check %v, profile %v", check, profile)
+		case <-time.After(10 * time.Millisecond):
+		}
+		for _, tr := range getHistory(t, server.Client(), server.Url("/history?from="+start), http.StatusOK) {
+			tr := tr
+			if tr.CheckID == "check2a" && tr.NewStatus == "critical" {
+				check = &tr
+			} else if tr.Selector == "profile/service2" && tr.NewStatus == string(checks.Failed) {
+				profile = &tr
+			}
+		}
+	}
+	if check.OldStatus != "passing" || check.ServiceName != "service2" || check.Node != server.GetConsulNodeName() {
+		t.Errorf("Check transition: got %+v", *check)
+	}
+	if profile.OldStatus != string(checks.Ok) {
+		t.Errorf("Profile transition: got %+v", *profile)
+	}
+
+	for _, tr := range getHistory(t, server.Client(), server.Url("/history?service=service1&from="+start), http.StatusOK) {
+		t.Errorf("Unexpected transition for service1: %+v", tr)
+	}
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	if transitions := getHistory(t, server.Client(), server.Url("/history?from="+future), http.StatusOK); len(transitions) != 0 {
+		t.Errorf("Unexpected transitions after %s: %+v", future, transitions)
+	}
+	if transitions := getHistory(t, server.Client(), server.Url("/history?limit=1&from="+start), http.StatusOK); len(transitions) != 1 {
+		t.Errorf("Transitions with a limit of 1: got %+v", transitions)
+	}
+	getHistory(t, server.Client(), server.Url("/history?to=yesterday"), http.StatusBadRequest)
+	getHistory(t, server.Client(), server.Url("/history?limit=0"), http.StatusBadRequest)
+	getHistory(t, server.Client(), server.Url("/history?limit=10001"), http.StatusBadRequest)
+
+	verifyAuditLog(t, path)
+}
+
+func TestHistoryWithoutAuditLog(t *testing.T) {
+	server := newServer(t)
+	defer server.Stop()
+
+	getHistory(t, server.Client(), server.Url("/history"), http.StatusNotFound)
+}
+
+func waitForStatus(t *testing.T, client *http.Client, url string, expectedCode int) {
+	timeout := time.After(5 * time.Second)
+	for {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == expectedCode {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("Status code: want %d, got %d", expectedCode, resp.StatusCode)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func verifyAuditLog(t *testing.T, path string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var tr server.Transition
+		if err := json.Unmarshal([]byte(line), &tr); err != nil {
+			t.Errorf("Audit log line %q is not JSON: %v", line, err)
+		}
+	}
+}
+
+func getHistory(t *testing.T, client *http.Client, url string, expectedCode int) []server.Transition {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != expectedCode {
+		t.Fatalf("Status code: want %d, got %d", expectedCode, resp.StatusCode)
+	}
+	var transitions []server.Transition
+	if expectedCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&transitions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return transitions
+}
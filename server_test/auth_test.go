@@ -0,0 +1,166 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"golang.org/x/crypto/bcrypt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type authRequest struct {
+	path     string
+	token    string
+	user     string
+	password string
+}
+
+func TestAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "tokens")
+	if err := ioutil.WriteFile(tokenFile, []byte("metrics1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	htpasswdFile := filepath.Join(dir, "htpasswd")
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(htpasswdFile, []byte("admin:"+string(hash)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.UnauthorizedStatusCode = 499
+		c.AuditConfig.Path = filepath.Join(dir, "audit.log")
+		c.AuthConfig.Rules = []string{
+			"verbose=bearer:verbose",
+			"verbose=htpasswd:" + htpasswdFile,
+			"metrics=token-file:" + tokenFile,
+			"admin=htpasswd:" + htpasswdFile,
+		}
+		c.AuthConfig.ReloadInterval = 10 * time.Millisecond
+	})
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	for _, d := range []struct {
+		request  authRequest
+		expected int
+	}{
+		{authRequest{path: "/verify/service/id/service2"}, http.StatusOK},
+		{authRequest{path: "/verify/service/id/service2?verbose"}, 499},
+		{authRequest{path: "/verify/service/id/service2?verbose", token: "other"}, 499},
+		{authRequest{path: "/verify/service/id/service2?verbose", token: "verbose"}, http.StatusOK},
+		{authRequest{path: "/verify/service/id/service2?verbose", user: "admin", password: "secret"}, http.StatusOK},
+		{authRequest{path: "/ui/", token: "verbose"}, http.StatusOK},
+		{authRequest{path: "/ui/"}, 499},
+		{authRequest{path: "/metrics"}, 499},
+		{authRequest{path: "/metrics", token: "verbose"}, 499},
+		{authRequest{path: "/metrics", token: "metrics1"}, http.StatusOK},
+		{authRequest{path: "/history", user: "admin", password: "wrong"}, 499},
+		{authRequest{path: "/history", token: "verbose"}, 499},
+		{authRequest{path: "/history", user: "admin", password: "secret"}, http.StatusOK},
+	} {
+		if code := authGet(t, server, d.request).StatusCode; code != d.expected {
+			t.Errorf("%+v: want %d, got %d", d.request, d.expected, code)
+		}
+	}
+
+	resp := authGet(t, server, authRequest{path: "/verify/service/id/service2?verbose"})
+	if challenges := resp.Header["Www-Authenticate"]; strings.Join(challenges, ", ") != `Bearer realm="consulate", Basic realm="consulate"` {
+		t.Errorf("WWW-Authenticate: got %v", challenges)
+	}
+
+	if err := ioutil.WriteFile(tokenFile, []byte("# rotated\nmetrics2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for authGet(t, server, authRequest{path: "/metrics", token: "metrics2"}).StatusCode != http.StatusOK {
+		select {
+		case <-timeout:
+			t.Fatal("Token file was not reloaded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if code := authGet(t, server, authRequest{path: "/metrics", token: "metrics1"}).StatusCode; code != 499 {
+		t.Errorf("Removed token: want 499, got %d", code)
+	}
+
+	resp = authGet(t, server, authRequest{path: "/metrics", token: "metrics2"})
+	body, _ := ioutil.ReadAll(resp.Body)
+	for _, expected := range []string{
+		`consulate_auth_failures_total{group="admin",reason="invalid"}`,
+		`consulate_auth_failures_total{group="metrics",reason="missing"}`,
+		`consulate_auth_failures_total{group="verbose",reason="missing"}`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Metrics do not contain %s", expected)
+		}
+	}
+}
+
+func TestInvalidAuth(t *testing.T) {
+	for expected, rule := range map[string]string{
+		`invalid auth rule "ui=bearer:token": unsupported group: ui`:                             "ui=bearer:token",
+		`invalid auth rule "verify=basic:token": unsupported method: basic`:                      "verify=basic:token",
+		`invalid auth rule "verify=token-file:missing": open missing: no such file or directory`: "verify=token-file:missing",
+	} {
+		server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+			c.AuthConfig.Rules = []string{rule}
+		})
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with invalid auth: %s", expected)
+		}
+		if err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
+
+// authGet requests the path with the bearer token or basic auth credentials of the request,
+// returning the response with its body read.
+func authGet(t *testing.T, server *testutil.WrappedTestServer, a authRequest) *http.Response {
+	req, err := http.NewRequest("GET", server.Url(a.path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	if a.user != "" {
+		req.SetBasicAuth(a.user, a.password)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	return resp
+}
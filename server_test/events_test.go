@@ -0,0 +1,189 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/server"
+	"github.com/kadaan/consulate/testutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseEvent struct {
+	id   string
+	name string
+	data string
+}
+
+type eventReader struct {
+	t       *testing.T
+	resp    *http.Response
+	scanner *bufio.Scanner
+}
+
+func openEvents(t *testing.T, s *testutil.WrappedTestServer, path string, lastEventID string) *eventReader {
+	req, err := http.NewRequest("GET", s.Url(path), nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	if resp.StatusCode != OK {
+		resp.Body.Close()
+		t.Fatalf("%s: want status code %d, got %d", path, OK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("%s: want Content-Type text/event-stream, got %s", path, contentType)
+	}
+	return &eventReader{t: t, resp: resp, scanner: bufio.NewScanner(resp.Body)}
+}
+
+func (e *eventReader) next() sseEvent {
+	var event sseEvent
+	for e.scanner.Scan() {
+		line := e.scanner.Text()
+		switch {
+		case line == "":
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	e.t.Fatalf("Event stream ended: %v", e.scanner.Err())
+	return event
+}
+
+// nextCheck skips events until the check event with the specified new status.
+func (e *eventReader) nextCheck(status checks.Status) (sseEvent, server.CheckEvent) {
+	for {
+		event := e.next()
+		var c server.CheckEvent
+		if event.name == "check" {
+			if err := json.Unmarshal([]byte(event.data), &c); err != nil {
+				e.t.Fatalf("Failed to parse check event: %v", err)
+			}
+			if c.NewStatus == status {
+				return event, c
+			}
+		}
+	}
+}
+
+// nextSelector skips events until the selector event with the specified new status.
+func (e *eventReader) nextSelector(status checks.ResultStatus) server.SelectorEvent {
+	for {
+		event := e.next()
+		var s server.SelectorEvent
+		if event.name == "selector" {
+			if err := json.Unmarshal([]byte(event.data), &s); err != nil {
+				e.t.Fatalf("Failed to parse selector event: %v", err)
+			}
+			if s.NewStatus == status {
+				return s
+			}
+		}
+	}
+}
+
+func (e *eventReader) close() {
+	e.resp.Body.Close()
+}
+
+func TestEvents(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"service3": "/verify/service/id/service3"}
+	})
+	defer server.Stop()
+
+	verifyApiCall(t, server, apiTestData{"/events?profile=unknown", BadRequest, `{"Status":"Failed","Detail":"Unknown profile: unknown"}`})
+
+	events := openEvents(t, server, "/events?service=service2", "")
+	defer events.close()
+	initial, c := events.nextCheck(checks.StatusPassing)
+	expected := `{"CheckID":"check2a","Name":"check 2","ServiceID":"service2","ServiceName":"service2","OldStatus":"","NewStatus":"passing","OldOutput":"","NewOutput":"Passing check"}`
+	if initial.data != expected {
+		t.Errorf("Initial event: want %s, got %s", expected, initial.data)
+	}
+	if s := events.nextSelector(checks.Ok); s.Selector != "service/service2" || s.OldStatus != "" {
+		t.Errorf("Initial selector event: got %+v", s)
+	}
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	if _, c = events.nextCheck(checks.StatusFailing); c.CheckID != "check2a" || c.OldStatus == "" {
+		t.Errorf("Check event: got %+v", c)
+	}
+	if s := events.nextSelector(checks.Failed); s.Selector != "service/service2" || s.OldStatus != checks.Ok {
+		t.Errorf("Selector event: got %+v", s)
+	}
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Still critical")
+	time.Sleep(50 * time.Millisecond)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+	event := events.next()
+	for event.name != "check" {
+		event = events.next()
+	}
+	if err := json.Unmarshal([]byte(event.data), &c); err != nil || c.NewStatus != checks.StatusPassing ||
+		c.OldStatus != checks.StatusFailing || c.OldOutput != "Still critical" {
+		t.Errorf("Check event after output change: got %s", event.data)
+	}
+
+	resumed := openEvents(t, server, "/events?service=service2", initial.id)
+	defer resumed.close()
+	if _, c = resumed.nextCheck(checks.StatusFailing); c.CheckID != "check2a" || c.OldStatus == "" {
+		t.Errorf("Resumed check event: got %+v", c)
+	}
+
+	profile := openEvents(t, server, "/events?profile=service3", "")
+	defer profile.close()
+	if s := profile.nextSelector(checks.Warning); s.Selector != "profile/service3" {
+		t.Errorf("Profile selector event: got %+v", s)
+	}
+}
+
+func TestEventsBeyondWriteTimeout(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.WriteTimeout = time.Second
+	})
+	defer server.Stop()
+
+	events := openEvents(t, server, "/events?check=check2a", "")
+	defer events.close()
+	events.nextCheck(checks.StatusPassing)
+	time.Sleep(2 * time.Second)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	if _, c := events.nextCheck(checks.StatusFailing); c.CheckID != "check2a" || c.OldStatus != checks.StatusPassing {
+		t.Errorf("Check event after the write timeout: got %+v", c)
+	}
+}
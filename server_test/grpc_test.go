@@ -0,0 +1,118 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGRPCHealth(t *testing.T) {
+	grpcAddr := fmt.Sprintf("127.0.0.1:%v", freeport.GetT(t, 1)[0])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.GRPCListenAddress = grpcAddr
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+	})
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, grpcAddr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("Failed to connect to gRPC server: %v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	for service, expected := range map[string]healthpb.HealthCheckResponse_ServingStatus{
+		"":         healthpb.HealthCheckResponse_SERVING,
+		"service1": healthpb.HealthCheckResponse_NOT_SERVING,
+		"service2": healthpb.HealthCheckResponse_SERVING,
+		"service3": healthpb.HealthCheckResponse_NOT_SERVING,
+	} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Errorf("Check(%q): %v", service, err)
+		} else if resp.Status != expected {
+			t.Errorf("Check(%q): want %v, got %v", service, expected, resp.Status)
+		}
+	}
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Check(%q): want %v, got %v", "unknown", codes.NotFound, err)
+	}
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "service2"})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	verifyWatch := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+		if resp.Status != expected {
+			t.Errorf("Watch: want %v, got %v", expected, resp.Status)
+		}
+	}
+	verifyWatch(healthpb.HealthCheckResponse_SERVING)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	verifyWatch(healthpb.HealthCheckResponse_NOT_SERVING)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+	verifyWatch(healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestInvalidWatchInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+			c.WatchInterval = interval
+		})
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with watch interval %v", interval)
+		}
+		if expected := fmt.Sprintf("invalid watcher: unsupported watch interval: %v", interval); err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
+
+func TestGRPCReleasedWhenStartFails(t *testing.T) {
+	grpcAddr := fmt.Sprintf("127.0.0.1:%v", freeport.GetT(t, 1)[0])
+	server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+		c.GRPCListenAddress = grpcAddr
+		c.AgentCheckConfig.Listeners = []string{"127.0.0.1:0=profile/unknown"}
+	})
+	if err == nil {
+		server.Stop()
+		t.Fatal("Server started with an invalid agent check listener")
+	}
+	listener, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		t.Fatalf("gRPC listener was not released when the server failed to start: %v", err)
+	}
+	listener.Close()
+}
@@ -0,0 +1,165 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/kadaan/consulate/config"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, nil)
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	waitForStatus(t, server.Client(), server.Url("/readyz"), http.StatusOK)
+	changes, err := server.Reload(func(c *config.ServerConfig) {
+		c.SuccessStatusCode = http.StatusAccepted
+		c.Profiles = map[string]string{"broken": "/verify/service/id/service1"}
+		c.CacheConfig.ConsulCacheDuration = 2 * time.Second
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"SuccessStatusCode: 200 -> 202",
+		"Profiles: [] -> map[broken:/verify/service/id/service1]",
+		"CacheConfig.ConsulCacheDuration: 1s -> 2s",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Changes => got: %q, want: %q", changes, expected)
+	}
+	for _, d := range []struct {
+		path     string
+		expected int
+	}{
+		{"/verify/service/id/service2", http.StatusAccepted},
+		{"/readyz", http.StatusServiceUnavailable},
+	} {
+		resp, err := server.Client().Get(server.Url(d.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != d.expected {
+			t.Errorf("%s: want %d, got %d", d.path, d.expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestReloadInvalidProfile(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, nil)
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	if _, err := server.Reload(func(c *config.ServerConfig) {
+		c.SuccessStatusCode = http.StatusAccepted
+		c.Profiles = map[string]string{"web": "/unknown"}
+	}); err == nil {
+		t.Fatal("expected an error for an invalid profile")
+	}
+	resp, err := server.Client().Get(server.Url("/verify/service/id/service2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Status code => got: %d, want: 200", resp.StatusCode)
+	}
+}
+
+func TestReloadListenerProfiles(t *testing.T) {
+	ports := freeport.GetT(t, 2)
+	tcpCheck := fmt.Sprintf("127.0.0.1:%v", ports[0])
+	agentCheck := fmt.Sprintf("127.0.0.1:%v", ports[1])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"web": "/verify/service/id/service1"}
+		c.TCPCheckConfig.Listeners = []string{tcpCheck + "=profile/web"}
+		c.AgentCheckConfig.Listeners = []string{agentCheck + "=profile/web"}
+	})
+	defer server.Stop()
+
+	waitForTCPCheck(t, tcpCheck, false)
+	waitForAgentCheck(t, agentCheck, "", "down")
+	if _, err := server.Reload(func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{"web": "/verify/service/id/service2"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitForTCPCheck(t, tcpCheck, true)
+	waitForAgentCheck(t, agentCheck, "", "up ready 100%")
+
+	_, err := server.Reload(func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{"api": "/verify/service/id/service2"}
+	})
+	if err == nil || !strings.Contains(err.Error(), `profile "web" is in use`) {
+		t.Errorf("Error => got: %v, want the profile in use", err)
+	}
+	waitForTCPCheck(t, tcpCheck, true)
+}
+
+func TestReloadWebhookProfiles(t *testing.T) {
+	bodies := make(chan string, 100)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies <- string(body)
+	}))
+	defer receiver.Close()
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"web": "/verify/service/id/service2"}
+		webhook := config.DefaultWebhookConfig()
+		webhook.URL = receiver.URL
+		webhook.Body = `{{.Selector}} is {{.NewStatus}}`
+		webhook.Profiles = []string{"web"}
+		c.Webhooks = []config.WebhookConfig{*webhook}
+	})
+	defer server.Stop()
+
+	waitForStatus(t, server.Client(), server.Url("/verify/service/id/service2"), http.StatusOK)
+	if _, err := server.Reload(func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{"web": "/verify/service/id/service3"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(5 * time.Second)
+	for body := ""; body != "profile/web is Warning"; {
+		select {
+		case body = <-bodies:
+		case <-timeout:
+			t.Fatal("Webhook was not sent for the reloaded profile")
+		}
+	}
+
+	_, err := server.Reload(func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{}
+	})
+	if err == nil || !strings.Contains(err.Error(), `profile "web" is in use`) {
+		t.Errorf("Error => got: %v, want the profile in use", err)
+	}
+}
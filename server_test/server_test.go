@@ -16,13 +16,16 @@ package server_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/kadaan/consulate/checks"
 	"github.com/kadaan/consulate/config"
 	"github.com/kadaan/consulate/testutil"
 	"io/ioutil"
+	"net/http"
 	"strings"
 	"testing"
 	"text/template"
+	"time"
 )
 
 var (
@@ -30,6 +33,7 @@ var (
 	PartialOK  = config.DefaultServerConfig().PartialSuccessStatusCode
 	NoChecks   = config.DefaultServerConfig().NoCheckStatusCode
 	CheckError = config.DefaultServerConfig().ErrorStatusCode
+	BadRequest = config.DefaultServerConfig().BadRequestStatusCode
 )
 
 var apiTests = []apiTestData{
@@ -50,9 +54,20 @@ var apiTests = []apiTestData{
 	{"/verify/service/name/unknown", NoChecks, `{"Status":"No Checks","Detail":"No checks for services with ServiceName: unknown"}`},
 	{"/verify/service/id/service1?status=critical", OK, `{"Status":"Ok"}`},
 	{"/verify/service/id/service2?verbose", OK, `{"Status":"Ok","Checks":{"check2a":{"Node":"{{.ConsulNodeName}}","CheckID":"check2a","Name":"check 2","Status":"passing","Output":"Passing check","ServiceID":"service2","ServiceName":"service2"}}}`},
+	{"/verify/service/id/service2?verbose=full", OK, `{"Status":"Ok","Checks":{"check2a":{"Node":"{{.ConsulNodeName}}","CheckID":"check2a","Name":"check 2","Status":"passing","Output":"Passing check","ServiceID":"service2","ServiceName":"service2","Type":"ttl","Definition":{}}}}`},
 	{"/verify/service/id/service2?pretty", OK, `{
     "Status": "Ok"
 }`},
+	{"/verify/service/name/unknown?format=health", NoChecks, `{"status":"fail","description":"/verify/service/name/unknown","output":"No checks for services with ServiceName: unknown"}`},
+	{"/verify/service/id/service2?format=bogus", BadRequest, `{"Status":"Failed","Detail":"Unsupported format: bogus"}`},
+	{"/verify/service/id/service2?index=1&wait=bogus", BadRequest, `{"Status":"Failed","Detail":"Unsupported wait: bogus"}`},
+	{"/verify/service/id/service2?index=1&wait=10m", BadRequest, `{"Status":"Failed","Detail":"Unsupported wait: 10m exceeds the max wait of 5m0s"}`},
+	{"/verify/service/id/service2?index=bogus", BadRequest, `{"Status":"Failed","Detail":"Unsupported index: bogus"}`},
+	{"/health?format=health", OK, `{"status":"pass","description":"/health"}`},
+	{"/livez", OK, `ok`},
+	{"/livez?verbose", OK, "[+]ping ok\nlivez check passed"},
+	{"/readyz", OK, `ok`},
+	{"/readyz?verbose&exclude=consul&exclude=unknown", OK, "[+]consul excluded: ok\nwarn: some health checks cannot be excluded: no matches for \"unknown\"\nreadyz check passed"},
 	{"/verify/service/id/service3", PartialOK, `{"Status":"Warning","Counts":{"failing":0,"passing":1,"warning":1},"Checks":{"check3b":{"Node":"{{.ConsulNodeName}}","CheckID":"check3b","Name":"check 3","Status":"warning","Output":"Warning check","ServiceID":"service3","ServiceName":"service3"}}}`},
 }
 
@@ -69,21 +84,9 @@ type bodyTemplateData struct {
 func TestApi(t *testing.T) {
 	t.Log("Starting API tests...")
 
-	server := newServer(t)
+	server := newServerWithChecks(t)
 	defer server.Stop()
 
-	server.AddService("service1", []string{})
-	server.AddCheck("check1a", "check 1", "service1", checks.HealthPassing, "Passing check")
-	server.AddCheck("check1b", "check 1", "service1", checks.HealthWarning, "Warning check")
-	server.AddCheck("check1c", "check 1", "service1", checks.HealthCritical, "Critical check")
-
-	server.AddService("service2", []string{})
-	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
-
-	server.AddService("service3", []string{})
-	server.AddCheck("check3a", "check 3", "service3", checks.HealthPassing, "Passing check")
-	server.AddCheck("check3b", "check 3", "service3", checks.HealthWarning, "Warning check")
-
 	for _, d := range apiTests {
 		t.Logf("  --> %s", d.path)
 		verifyApiCall(t, server, d)
@@ -91,6 +94,334 @@ func TestApi(t *testing.T) {
 	t.Log("Finished API tests")
 }
 
+func TestDashboard(t *testing.T) {
+	server := newServerWithChecks(t)
+	defer server.Stop()
+
+	verifyHtmlCall(t, server, "/ui", OK, "check1a", "check1c", "check2a", "check3b", "Critical check")
+	verifyHtmlCall(t, server, "/verify/service/id/service3", PartialOK, "check3a", "check3b", "Warning check")
+	verifyHtmlCall(t, server, "/verify/service/name/unknown", NoChecks, "No checks for services with ServiceName: unknown")
+}
+
+func TestReadyz(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{
+			"service1": "/verify/service/id/service1",
+			"service2": "/verify/service/id/service2",
+			"service3": "/verify/service/id/service3?status=warning",
+		}
+	})
+	defer server.Stop()
+
+	for _, d := range []apiTestData{
+		{"/readyz", CheckError, "[+]consul ok\n[-]profile/service1 failed: reason withheld\n[+]profile/service2 ok\n[+]profile/service3 ok\nreadyz check failed"},
+		{"/readyz?exclude=profile/service1", OK, "ok"},
+		{"/readyz?verbose&exclude=profile/service1", OK, "[+]consul ok\n[+]profile/service1 excluded: ok\n[+]profile/service2 ok\n[+]profile/service3 ok\nreadyz check passed"},
+	} {
+		verifyApiCall(t, server, d)
+	}
+}
+
+func TestBlockingQuery(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+	})
+	defer server.Stop()
+
+	get := func(path string) (int, string) {
+		r, err := server.Client().Get(server.Url(path))
+		if err != nil {
+			t.Fatalf("FAILURE (get): %q => Error: %s", path, err)
+		}
+		r.Body.Close()
+		index := r.Header.Get("X-Consulate-Index")
+		if index == "" {
+			t.Errorf("FAILURE (get): %q => X-Consulate-Index is missing", path)
+		}
+		return r.StatusCode, index
+	}
+
+	_, index := get("/verify/service/name/service2")
+	start := time.Now()
+	if code, i := get("/verify/service/name/service2?wait=200ms&index=" + index); code != OK || i != index {
+		t.Errorf("Unchanged: StatusCode: %v, want %v; X-Consulate-Index: %v, want %v", code, OK, i, index)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("Unchanged: returned after %v, want at least 200ms", elapsed)
+	}
+	if code, i := get("/verify/service/name/service2?wait=200ms&index=1"); code != OK || i != index {
+		t.Errorf("Different index: StatusCode: %v, want %v; X-Consulate-Index: %v, want %v", code, OK, i, index)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	}()
+	start = time.Now()
+	code, i := get("/verify/service/name/service2?wait=3s&index=" + index)
+	if code != CheckError || i == index {
+		t.Errorf("Changed: StatusCode: %v, want %v; X-Consulate-Index: %v, want other than %v", code, CheckError, i, index)
+	}
+	if elapsed := time.Since(start); elapsed >= 3*time.Second {
+		t.Errorf("Changed: returned after %v, want less than 3s", elapsed)
+	}
+}
+
+func TestBlockingQueryBeyondWriteTimeout(t *testing.T) {
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.WriteTimeout = time.Second
+		c.WatchInterval = 10 * time.Millisecond
+	})
+	defer server.Stop()
+
+	r, err := server.Client().Get(server.Url("/verify/service/name/service2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Body.Close()
+	index := r.Header.Get("X-Consulate-Index")
+	start := time.Now()
+	r, err = server.Client().Get(server.Url("/verify/service/name/service2?wait=2s&index=" + index))
+	if err != nil {
+		t.Fatalf("Blocking query longer than the write timeout => Error: %s", err)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil || r.StatusCode != OK || string(body) != `{"Status":"Ok"}` {
+		t.Errorf("Blocking query longer than the write timeout => StatusCode: %v, Body: %s, Error: %v", r.StatusCode, body, err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("Blocking query returned after %v, want at least 2s", elapsed)
+	}
+}
+
+func TestSummaryHeaders(t *testing.T) {
+	server := newServerWithChecks(t)
+	defer server.Stop()
+
+	for path, expected := range map[string][]string{
+		"/verify/checks":                 {"Failed", "3", "2", "1", "check1c"},
+		"/verify/checks?status=critical": {"Ok", "6", "0", "0", ""},
+		"/verify/service/id/service1":    {"Failed", "1", "1", "1", "check1c"},
+		"/verify/service/id/service3":    {"Warning", "1", "1", "0", ""},
+		"/verify/service/name/unknown":   {"No Checks", "0", "0", "0", ""},
+		"/verify/service/id/service2":    {"Ok", "1", "0", "0", ""},
+	} {
+		r, err := server.Client().Head(server.Url(path))
+		if err != nil {
+			t.Fatalf("FAILURE (head): %q => Error: %s", path, err)
+		}
+		for i, header := range []string{"X-Consulate-Status", "X-Consulate-Passing", "X-Consulate-Warning", "X-Consulate-Failing", "X-Consulate-Failing-Checks"} {
+			if actual := r.Header.Get(header); actual != expected[i] {
+				t.Errorf("FAILURE (head): %q => %s: %q, want %q", path, header, actual, expected[i])
+			}
+		}
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	server := newServerWithChecks(t)
+	defer server.Stop()
+
+	get := func(method string, path string, ifNoneMatch string) *http.Response {
+		req, err := http.NewRequest(method, server.Url(path), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		r, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatalf("FAILURE (%s): %q => Error: %s", method, path, err)
+		}
+		r.Body.Close()
+		expected := ""
+		if r.StatusCode == http.StatusNotModified || (r.StatusCode >= 200 && r.StatusCode <= 299) {
+			expected = "max-age=1"
+		}
+		if cacheControl := r.Header.Get("Cache-Control"); cacheControl != expected {
+			t.Errorf("FAILURE (%s): %q => Cache-Control: %q, want %q", method, path, cacheControl, expected)
+		}
+		return r
+	}
+
+	path := "/verify/service/id/service2?verbose"
+	etag := get("GET", path, "").Header.Get("ETag")
+	if etag == "" {
+		t.Fatalf("FAILURE (get): %q => ETag is missing", path)
+	}
+	for _, d := range []struct {
+		method      string
+		path        string
+		ifNoneMatch string
+		statusCode  int
+	}{
+		{"GET", path, etag, http.StatusNotModified},
+		{"HEAD", path, etag, http.StatusNotModified},
+		{"GET", path, `"other", W/` + etag, http.StatusNotModified},
+		{"GET", path, "*", http.StatusNotModified},
+		{"GET", path, `"other"`, OK},
+		{"GET", path + "&pretty", etag, OK},
+		{"GET", "/verify/service/id/service2", etag, OK},
+	} {
+		if r := get(d.method, d.path, d.ifNoneMatch); r.StatusCode != d.statusCode {
+			t.Errorf("FAILURE (%s): %q If-None-Match %s => StatusCode: %v, want %v", d.method, d.path, d.ifNoneMatch, r.StatusCode, d.statusCode)
+		}
+	}
+	for _, p := range []string{"/verify/checks", "/verify/service/id/service2?format=html"} {
+		if r := get("GET", p, "*"); r.StatusCode == http.StatusNotModified || r.Header.Get("ETag") != "" {
+			t.Errorf("FAILURE (get): %q => StatusCode: %v, ETag: %q, want neither 304 nor an ETag", p, r.StatusCode, r.Header.Get("ETag"))
+		}
+	}
+	for _, p := range []string{"/verify/service/id/service1", "/verify/service/id/service1?format=html"} {
+		if r := get("GET", p, ""); r.StatusCode >= 200 && r.StatusCode <= 299 {
+			t.Errorf("FAILURE (get): %q => StatusCode: %v, want an unsuccessful response", p, r.StatusCode)
+		}
+	}
+}
+
+func TestCheckDefinition(t *testing.T) {
+	server := newServer(t)
+	defer server.Stop()
+	server.AddService("service1", []string{})
+	server.AddHTTPCheck("check1a", "check 1", "service1", "http://127.0.0.1:1/health", "10s", "1s")
+
+	for path, expected := range map[string]*checks.CheckDefinition{
+		"/verify/checks/id/check1a?verbose=full": {HTTP: "http://127.0.0.1:1/health", Interval: 10 * time.Second, Timeout: time.Second},
+		"/verify/checks/id/check1a?verbose":      nil,
+	} {
+		r, err := server.Client().Get(server.Url(path))
+		if err != nil {
+			t.Fatalf("FAILURE (get): %q => Error: %s", path, err)
+		}
+		var result checks.Result
+		err = json.NewDecoder(r.Body).Decode(&result)
+		r.Body.Close()
+		if err != nil {
+			t.Fatalf("FAILURE (get): %q => Error: %s", path, err)
+		}
+		c := result.Checks["check1a"]
+		if c == nil {
+			t.Fatalf("FAILURE (get): %q => check1a is missing", path)
+		}
+		if expected == nil {
+			if c.Type != "" || c.Definition != nil {
+				t.Errorf("FAILURE (get): %q => Type: %q, Definition: %+v, want neither", path, c.Type, c.Definition)
+			}
+			continue
+		}
+		if c.Type != "http" {
+			t.Errorf("FAILURE (get): %q => Type: %q, want %q", path, c.Type, "http")
+		}
+		if c.Definition == nil || c.Definition.HTTP != expected.HTTP || c.Definition.Interval != expected.Interval ||
+			c.Definition.Timeout != expected.Timeout || c.Definition.Target() != expected.HTTP {
+			t.Errorf("FAILURE (get): %q => Definition: %+v, want %+v", path, c.Definition, expected)
+		}
+	}
+}
+
+func TestInvalidProfile(t *testing.T) {
+	server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+		c.Profiles = map[string]string{"invalid": "/about"}
+	})
+	if err == nil {
+		server.Stop()
+		t.Fatal("Server started with an invalid profile")
+	}
+	expected := `invalid profile "invalid": unsupported route: /about`
+	if err.Error() != expected {
+		t.Errorf("Error: %q, want %q", err.Error(), expected)
+	}
+}
+
+func TestHealthJSON(t *testing.T) {
+	server := newServerWithChecks(t)
+	defer server.Stop()
+
+	req, err := http.NewRequest("GET", server.Url("/verify/service/id/service1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/health+json")
+	r, err := server.Client().Do(req)
+	if r != nil && r.Body != nil {
+		defer r.Body.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != CheckError {
+		t.Errorf("StatusCode: %v, want %v", r.StatusCode, CheckError)
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "application/health+json" {
+		t.Errorf("Content-Type: %v, want application/health+json", contentType)
+	}
+	var body struct {
+		Status string
+		Checks map[string][]struct {
+			ComponentID   string
+			ComponentType string
+			ObservedValue string
+			Status        string
+			Output        string
+			Time          string
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != "fail" {
+		t.Errorf("status: %v, want fail", body.Status)
+	}
+	components := body.Checks["service1:check 1"]
+	if len(components) != 3 {
+		t.Fatalf("checks: %v, want 3 components", body.Checks)
+	}
+	expected := []struct{ id, observedValue, status string }{
+		{"check1a", "passing", "pass"},
+		{"check1b", "warning", "warn"},
+		{"check1c", "critical", "fail"},
+	}
+	for i, e := range expected {
+		c := components[i]
+		if c.ComponentID != e.id || c.ObservedValue != e.observedValue || c.Status != e.status || c.ComponentType != "component" || c.Time == "" {
+			t.Errorf("check %d: %+v, want %+v", i, c, e)
+		}
+	}
+}
+
+func verifyHtmlCall(t *testing.T, s *testutil.WrappedTestServer, path string, statusCode int, contains ...string) {
+	req, err := http.NewRequest("GET", s.Url(path), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	r, err := s.Client().Do(req)
+	if r != nil && r.Body != nil {
+		defer r.Body.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != statusCode {
+		t.Errorf("FAILURE (html): %q => StatusCode: %v, want %v", path, r.StatusCode, statusCode)
+	}
+	if contentType := r.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+		t.Errorf("FAILURE (html): %q => Content-Type: %v, want text/html", path, contentType)
+	}
+	bb, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Errorf("FAILURE (html): %q => Error: %s", path, err)
+	}
+	for _, c := range contains {
+		if !strings.Contains(string(bb), c) {
+			t.Errorf("FAILURE (html): %q => Body does not contain %q", path, c)
+		}
+	}
+}
+
 func verifyApiCall(t *testing.T, s *testutil.WrappedTestServer, d apiTestData) {
 	verifyHeadApiCall(t, s, d.path, d.statusCode)
 	verifyHeadApiCall(t, s, appendTrailingSlash(d.path), d.statusCode)
@@ -144,8 +475,33 @@ func verifyGetApiCall(t *testing.T, s *testutil.WrappedTestServer, path string,
 	}
 }
 
+func newServerWithChecks(t *testing.T) *testutil.WrappedTestServer {
+	return newServerWithConfigAndChecks(t, nil)
+}
+
+func newServerWithConfigAndChecks(t *testing.T, configure func(c *config.ServerConfig)) *testutil.WrappedTestServer {
+	server := newServerWithConfig(t, configure)
+
+	server.AddService("service1", []string{})
+	server.AddCheck("check1a", "check 1", "service1", checks.HealthPassing, "Passing check")
+	server.AddCheck("check1b", "check 1", "service1", checks.HealthWarning, "Warning check")
+	server.AddCheck("check1c", "check 1", "service1", checks.HealthCritical, "Critical check")
+
+	server.AddService("service2", []string{})
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+
+	server.AddService("service3", []string{})
+	server.AddCheck("check3a", "check 3", "service3", checks.HealthPassing, "Passing check")
+	server.AddCheck("check3b", "check 3", "service3", checks.HealthWarning, "Warning check")
+	return server
+}
+
 func newServer(t *testing.T) *testutil.WrappedTestServer {
-	server, err := testutil.NewTestServer(t)
+	return newServerWithConfig(t, nil)
+}
+
+func newServerWithConfig(t *testing.T, configure func(c *config.ServerConfig)) *testutil.WrappedTestServer {
+	server, err := testutil.NewTestServerWithConfig(t, configure)
 	if err != nil {
 		if server != nil {
 			defer server.Stop()
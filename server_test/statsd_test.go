@@ -0,0 +1,84 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receiveMetric waits for a metric with the prefix and suffix, returning the whole metric.
+func receiveMetric(t *testing.T, conn *net.UDPConn, prefix string, suffix string) string {
+	buf := make([]byte, 1024)
+	deadline := time.Now().Add(5 * time.Second)
+	conn.SetReadDeadline(deadline)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Metric %s...%s was not sent: %v", prefix, suffix, err)
+		}
+		if metric := string(buf[:n]); strings.HasPrefix(metric, prefix) && strings.HasSuffix(metric, suffix) {
+			return metric
+		}
+	}
+}
+
+func TestStatsD(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.StatsDConfig.Address = conn.LocalAddr().String()
+		c.StatsDConfig.Interval = 50 * time.Millisecond
+	})
+	defer server.Stop()
+
+	receiveMetric(t, conn, "consulate.checks:1|g|#service:service2,status:passing", "")
+	verifyGetApiCall(t, server, "/verify/service/id/service2", OK, `{"Status":"Ok"}`)
+	receiveMetric(t, conn, "consulate.requests:1|c|", "#code:200,method:GET,url:/verify/service/id/:service")
+	receiveMetric(t, conn, "consulate.request_duration:", "|ms|#code:200,method:GET,url:/verify/service/id/:service")
+	receiveMetric(t, conn, "consulate.client.requests:1|c|", "#code:200,method:GET")
+	receiveMetric(t, conn, "consulate.client.request_duration:", "|ms|#code:200,method:GET")
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	receiveMetric(t, conn, "consulate.checks:1|g|#service:service2,status:critical", "")
+	receiveMetric(t, conn, "consulate.checks:0|g|#service:service2,status:passing", "")
+}
+
+func TestInvalidStatsDInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+			c.StatsDConfig.Address = "127.0.0.1:8125"
+			c.StatsDConfig.Interval = interval
+		})
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with StatsD interval %v", interval)
+		}
+		if expected := "invalid statsd: unsupported interval: " + interval.String(); err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
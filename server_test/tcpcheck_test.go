@@ -0,0 +1,106 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"fmt"
+	"github.com/hashicorp/consul/sdk/freeport"
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTCPCheck(t *testing.T) {
+	ports := freeport.GetT(t, 2)
+	service2 := fmt.Sprintf("127.0.0.1:%v", ports[0])
+	service3 := fmt.Sprintf("127.0.0.1:%v", ports[1])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.Profiles = map[string]string{"service3": "/verify/service/id/service3"}
+		c.TCPCheckConfig.Listeners = []string{service2 + "=service2", service3 + "=profile/service3"}
+	})
+	defer server.Stop()
+
+	waitForTCPCheck(t, service2, true)
+	waitForTCPCheck(t, service3, false)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	waitForTCPCheck(t, service2, false)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+	waitForTCPCheck(t, service2, true)
+}
+
+func TestTCPCheckRetriesListen(t *testing.T) {
+	address := fmt.Sprintf("127.0.0.1:%v", freeport.GetT(t, 1)[0])
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		c.TCPCheckConfig.Listeners = []string{address + "=service2"}
+	})
+	defer server.Stop()
+
+	waitForTCPCheck(t, address, true)
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	waitForTCPCheck(t, address, false)
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthPassing, "Passing check")
+	time.Sleep(100 * time.Millisecond)
+	listener.Close()
+	waitForTCPCheck(t, address, true)
+}
+
+func TestInvalidTCPCheck(t *testing.T) {
+	for spec, expected := range map[string]string{
+		"127.0.0.1:0":                  `invalid TCP check listener "127.0.0.1:0": no selector`,
+		"127.0.0.1:0=profile/unknown":  `invalid TCP check listener "127.0.0.1:0=profile/unknown": unknown profile: unknown`,
+		"127.0.0.1:0=/verify/unknown/": `invalid TCP check listener "127.0.0.1:0=/verify/unknown/": unsupported route: /verify/unknown/`,
+	} {
+		server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+			c.TCPCheckConfig.Listeners = []string{spec}
+		})
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with an invalid TCP check listener: %s", spec)
+		}
+		if err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
+
+// waitForTCPCheck waits until connections to the address are accepted, or refused.
+func waitForTCPCheck(t *testing.T, address string, accepted bool) {
+	timeout := time.After(5 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		if (err == nil) == accepted {
+			return
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("TCP check %s: want accepted %v, got error %v", address, accepted, err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
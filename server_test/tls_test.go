@@ -0,0 +1,219 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	ca := testutil.NewCertificateAuthority(t, "Test CA")
+	first := ca.Issue(t, "consulate-1")
+	first.Write(t, certFile, keyFile)
+
+	server := newServerWithConfig(t, func(c *config.ServerConfig) {
+		c.TLSConfig.CertFile = certFile
+		c.TLSConfig.KeyFile = keyFile
+		c.TLSConfig.ReloadInterval = 10 * time.Millisecond
+	})
+	defer server.Stop()
+
+	address := serverAddress(t, server)
+	if cn := peerCommonName(t, address, &tls.Config{RootCAs: ca.CertPool()}); cn != "consulate-1" {
+		t.Errorf("Certificate: want consulate-1, got %s", cn)
+	}
+	if _, err := tls.Dial("tcp", address, &tls.Config{RootCAs: ca.CertPool(), MaxVersion: tls.VersionTLS11}); err == nil {
+		t.Error("TLS 1.1 was accepted")
+	}
+
+	second := ca.Issue(t, "consulate-2")
+	second.Write(t, certFile, keyFile)
+	timeout := time.After(5 * time.Second)
+	for peerCommonName(t, address, &tls.Config{RootCAs: ca.CertPool()}) != "consulate-2" {
+		select {
+		case <-timeout:
+			t.Fatal("Certificate was not reloaded")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	resp, err := server.Client().Get(server.Url("/metrics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	expected := fmt.Sprintf("consulate_tls_certificate_expiry_timestamp_seconds %v", float64(second.Cert.NotAfter.Unix()))
+	if !strings.Contains(string(body), expected) {
+		t.Errorf("Metrics do not contain %s", expected)
+	}
+	if !strings.Contains(string(body), `consulate_tls_certificate_reloads_total{result="success"}`) {
+		t.Error("Metrics do not contain successful reloads")
+	}
+}
+
+func TestInvalidTLS(t *testing.T) {
+	for expected, configure := range map[string]func(c *config.TLSConfig){
+		"invalid TLS: both a certificate and a key file are required": func(c *config.TLSConfig) {
+			c.CertFile = "cert.pem"
+		},
+		"invalid TLS: unsupported minimum version: 1.4": func(c *config.TLSConfig) {
+			c.CertFile, c.KeyFile, c.MinVersion = "cert.pem", "key.pem", "1.4"
+		},
+		"invalid TLS: unsupported cipher suite: TLS_UNKNOWN": func(c *config.TLSConfig) {
+			c.CertFile, c.KeyFile, c.CipherSuites = "cert.pem", "key.pem", []string{"TLS_UNKNOWN"}
+		},
+		"invalid TLS: stat missing.pem: no such file or directory": func(c *config.TLSConfig) {
+			c.CertFile, c.KeyFile = "missing.pem", "missing.pem"
+		},
+		"invalid TLS: a client CA file requires a certificate and a key file": func(c *config.TLSConfig) {
+			c.ClientCAFile = "ca.pem"
+		},
+		"invalid TLS: client policies require a client CA file": func(c *config.TLSConfig) {
+			c.ClientPolicies = []string{"/ui=ops"}
+		},
+		`invalid TLS client policy "ui=ops": unsupported pattern: ui`: func(c *config.TLSConfig) {
+			c.ClientCAFile, c.ClientPolicies = "ca.pem", []string{"ui=ops"}
+		},
+		`invalid TLS client policy "/ui=": no names`: func(c *config.TLSConfig) {
+			c.ClientCAFile, c.ClientPolicies = "ca.pem", []string{"/ui="}
+		},
+	} {
+		server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+			configure(&c.TLSConfig)
+		})
+		if err == nil {
+			server.Stop()
+			t.Fatalf("Server started with invalid TLS: %s", expected)
+		}
+		if err.Error() != expected {
+			t.Errorf("Error: %q, want %q", err.Error(), expected)
+		}
+	}
+}
+
+func TestClientCertificatePolicies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "consulate-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	caFile := filepath.Join(dir, "ca.pem")
+	ca := testutil.NewCertificateAuthority(t, "Test CA")
+	ca.Issue(t, "consulate").Write(t, certFile, keyFile)
+	if err := ioutil.WriteFile(caFile, ca.CertPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.TLSConfig.CertFile = certFile
+		c.TLSConfig.KeyFile = keyFile
+		c.TLSConfig.ClientCAFile = caFile
+		c.TLSConfig.ClientPolicies = []string{"verbose=ops", "/ui=ops,localhost", "/openapi.json=*", "/metrics=ops"}
+	})
+	defer server.Stop()
+
+	// Every issued certificate has localhost as a subject alternative name.
+	clients := map[string]*http.Client{
+		"none":  tlsClient(t, ca, nil),
+		"ops":   tlsClient(t, ca, ca.Issue(t, "ops")),
+		"other": tlsClient(t, ca, ca.Issue(t, "other")),
+	}
+	for _, d := range []struct {
+		client   string
+		path     string
+		expected int
+	}{
+		{"none", "/verify/service/id/service2", http.StatusOK},
+		{"none", "/verify/service/id/service2?verbose", http.StatusForbidden},
+		{"none", "/ui", http.StatusForbidden},
+		{"none", "/openapi.json", http.StatusForbidden},
+		{"none", "/metrics", http.StatusForbidden},
+		{"ops", "/metrics", http.StatusOK},
+		{"ops", "/verify/service/id/service2?verbose", http.StatusOK},
+		{"ops", "/ui/", http.StatusOK},
+		{"other", "/verify/service/id/service2", http.StatusOK},
+		{"other", "/verify/service/id/service2?verbose", http.StatusForbidden},
+		{"other", "/ui", http.StatusOK},
+		{"other", "/openapi.json", http.StatusOK},
+	} {
+		resp, err := clients[d.client].Get(server.Url(d.path))
+		if err != nil {
+			t.Errorf("%s %s: %v", d.client, d.path, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != d.expected {
+			t.Errorf("%s %s: want %d, got %d", d.client, d.path, d.expected, resp.StatusCode)
+		}
+	}
+
+	untrusted := testutil.NewCertificateAuthority(t, "Untrusted CA").Issue(t, "ops").TLSCertificate(t)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: ca.CertPool(),
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return &untrusted, nil
+		},
+	}}}
+	if resp, err := client.Get(server.Url("/about")); err == nil {
+		resp.Body.Close()
+		t.Error("Client certificate from an untrusted CA was accepted")
+	}
+}
+
+func tlsClient(t *testing.T, ca *testutil.Certificate, cert *testutil.Certificate) *http.Client {
+	c := &tls.Config{RootCAs: ca.CertPool()}
+	if cert != nil {
+		c.Certificates = []tls.Certificate{cert.TLSCertificate(t)}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: c}}
+}
+
+func serverAddress(t *testing.T, server *testutil.WrappedTestServer) string {
+	u, err := url.Parse(server.Url("/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
+
+func peerCommonName(t *testing.T, address string, c *tls.Config) string {
+	conn, err := tls.Dial("tcp", address, c)
+	if err != nil {
+		t.Fatalf("Failed to connect with TLS: %v", err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
@@ -0,0 +1,131 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"encoding/hex"
+	"github.com/kadaan/consulate/config"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeCollector struct {
+	*httptest.Server
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func newFakeCollector(t *testing.T) *fakeCollector {
+	f := &fakeCollector{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		var export collectorpb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(body, &export); err != nil {
+			t.Errorf("Failed to decode traces: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, rs := range export.ResourceSpans {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				f.spans = append(f.spans, ils.Spans...)
+			}
+		}
+	}))
+	return f
+}
+
+// find gets the spans of the trace with the name.
+func (f *fakeCollector) find(traceID string, name string) []*tracepb.Span {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var spans []*tracepb.Span
+	for _, span := range f.spans {
+		if hex.EncodeToString(span.TraceId) == traceID && span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func attribute(span *tracepb.Span, key string) *commonpb.AnyValue {
+	for _, a := range span.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	collector := newFakeCollector(t)
+	defer collector.Close()
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.TracingConfig.Endpoint = strings.TrimPrefix(collector.URL, "http://")
+		c.TracingConfig.Insecure = true
+	})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest("GET", server.Url("/verify/service/id/service2"), nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		server.Stop()
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	server.Stop()
+
+	requests := collector.find(traceID, "/verify/service/id/:service")
+	if len(requests) != 1 {
+		t.Fatalf("want 1 request span, got %d", len(requests))
+	}
+	request := requests[0]
+	if parent := hex.EncodeToString(request.ParentSpanId); parent != "00f067aa0ba902b7" {
+		t.Errorf("Request parent: want 00f067aa0ba902b7, got %s", parent)
+	}
+	if route := attribute(request, "http.route").GetStringValue(); route != "/verify/service/id/:service" {
+		t.Errorf("Request http.route: want /verify/service/id/:service, got %s", route)
+	}
+	for _, name := range []string{"cache lookup", "evaluate"} {
+		spans := collector.find(traceID, name)
+		if len(spans) != 1 {
+			t.Errorf("want 1 %s span, got %d", name, len(spans))
+		} else if parent := hex.EncodeToString(spans[0].ParentSpanId); parent != hex.EncodeToString(request.SpanId) {
+			t.Errorf("%s parent: want %s, got %s", name, hex.EncodeToString(request.SpanId), parent)
+		}
+	}
+	if spans := collector.find(traceID, "cache lookup"); len(spans) == 1 && attribute(spans[0], "consulate.cache.hit") == nil {
+		t.Error("cache lookup: want consulate.cache.hit attribute")
+	}
+	if spans := collector.find(traceID, "evaluate"); len(spans) == 1 {
+		if verdict := attribute(spans[0], "consulate.verdict").GetStringValue(); verdict != "Ok" {
+			t.Errorf("evaluate consulate.verdict: want Ok, got %s", verdict)
+		}
+		if matched := attribute(spans[0], "consulate.checks.matched").GetIntValue(); matched != 1 {
+			t.Errorf("evaluate consulate.checks.matched: want 1, got %d", matched)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server_test
+
+import (
+	"github.com/kadaan/consulate/checks"
+	"github.com/kadaan/consulate/config"
+	"github.com/kadaan/consulate/testutil"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	var attempts int32
+	bodies := make(chan string, 100)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if token := req.Header.Get("X-Token"); token != "secret" {
+			t.Errorf("X-Token: want secret, got %s", token)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies <- string(body)
+	}))
+	defer receiver.Close()
+
+	server := newServerWithConfigAndChecks(t, func(c *config.ServerConfig) {
+		c.CacheConfig.ConsulCacheDuration = 10 * time.Millisecond
+		c.WatchInterval = 10 * time.Millisecond
+		webhook := config.DefaultWebhookConfig()
+		webhook.Name = "test"
+		webhook.URL = receiver.URL
+		webhook.Headers["X-Token"] = "secret"
+		webhook.Body = `{"text":"{{.Selector}} is {{.NewStatus}}"}`
+		webhook.Selectors = []string{"/verify/service/id/service2"}
+		webhook.InitialBackoff = 10 * time.Millisecond
+		c.Webhooks = []config.WebhookConfig{*webhook}
+	})
+	defer server.Stop()
+
+	server.AddCheck("check2a", "check 2", "service2", checks.HealthCritical, "Critical check")
+	expected := `{"text":"/verify/service/id/service2 is Failed"}`
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case body := <-bodies:
+			if body == expected {
+				if atomic.LoadInt32(&attempts) < 2 {
+					t.Errorf("Failed webhook was not retried")
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("Webhook was not sent: %s", expected)
+		}
+	}
+}
+
+func TestInvalidWebhook(t *testing.T) {
+	server, err := testutil.NewTestServerWithConfig(t, func(c *config.ServerConfig) {
+		webhook := config.DefaultWebhookConfig()
+		webhook.Name = "invalid"
+		webhook.URL = "http://localhost/hook"
+		webhook.Profiles = []string{"unknown"}
+		c.Webhooks = []config.WebhookConfig{*webhook}
+	})
+	if err == nil {
+		server.Stop()
+		t.Fatal("Server started with an invalid webhook")
+	}
+	expected := `invalid webhook "invalid": unknown profile: unknown`
+	if err.Error() != expected {
+		t.Errorf("Error: %q, want %q", err.Error(), expected)
+	}
+}
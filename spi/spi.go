@@ -14,6 +14,8 @@
 
 package spi
 
+import "github.com/kadaan/consulate/config"
+
 // Server represents a server that can be started.
 type Server interface {
 	// Start begins the Server.
@@ -24,4 +26,7 @@ type Server interface {
 type RunningServer interface {
 	// Stop terminates the current RunningServer.
 	Stop()
+
+	// Reload applies the config to the current RunningServer, and returns a summary of what changed.
+	Reload(c *config.ServerConfig) ([]string, error)
 }
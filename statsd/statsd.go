@@ -0,0 +1,152 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"github.com/kadaan/consulate/config"
+	"github.com/pkg/errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client sends metrics to a StatsD or DogStatsD server.  Tags are formatted by Tag.
+type Client interface {
+	// Count adds the value to a counter, subject to the sample rate.
+	Count(name string, value int64, tags ...string)
+
+	// Timing records a duration in a timer, subject to the sample rate.
+	Timing(name string, d time.Duration, tags ...string)
+
+	// Gauge sets the value of a gauge.
+	Gauge(name string, value float64, tags ...string)
+
+	// Close releases the connection to the server.
+	Close() error
+}
+
+// Tag formats a DogStatsD tag.
+func Tag(key string, value string) string {
+	return key + ":" + tagReplacer.Replace(value)
+}
+
+var (
+	tagReplacer  = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+	nameReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", "#", "_", " ", "_", "\n", "_")
+)
+
+type noOpClient struct {
+}
+
+func (c *noOpClient) Count(name string, value int64, tags ...string) {
+}
+
+func (c *noOpClient) Timing(name string, d time.Duration, tags ...string) {
+}
+
+func (c *noOpClient) Gauge(name string, value float64, tags ...string) {
+}
+
+func (c *noOpClient) Close() error {
+	return nil
+}
+
+type udpClient struct {
+	conn   net.Conn
+	prefix string
+	rate   float64
+	tags   bool
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+func (c *udpClient) Count(name string, value int64, tags ...string) {
+	c.send(name, strconv.FormatInt(value, 10), "c", true, tags)
+}
+
+func (c *udpClient) Timing(name string, d time.Duration, tags ...string) {
+	c.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", true, tags)
+}
+
+func (c *udpClient) Gauge(name string, value float64, tags ...string) {
+	c.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", false, tags)
+}
+
+func (c *udpClient) Close() error {
+	return c.conn.Close()
+}
+
+// send writes a metric in a single datagram.  Errors are ignored, as StatsD is lossy by design.
+func (c *udpClient) send(name string, value string, kind string, sampled bool, tags []string) {
+	sampled = sampled && c.rate < 1
+	if sampled && !c.sample() {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(c.prefix)
+	b.WriteString(name)
+	if !c.tags {
+		for _, tag := range tags {
+			if i := strings.Index(tag, ":"); i >= 0 {
+				tag = tag[i+1:]
+			}
+			b.WriteString(".")
+			b.WriteString(nameReplacer.Replace(tag))
+		}
+	}
+	b.WriteString(":")
+	b.WriteString(value)
+	b.WriteString("|")
+	b.WriteString(kind)
+	if sampled {
+		b.WriteString("|@")
+		b.WriteString(strconv.FormatFloat(c.rate, 'f', -1, 64))
+	}
+	if c.tags && len(tags) > 0 {
+		b.WriteString("|#")
+		b.WriteString(strings.Join(tags, ","))
+	}
+	c.conn.Write([]byte(b.String()))
+}
+
+func (c *udpClient) sample() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.random.Float64() < c.rate
+}
+
+// NewClient creates a new statsd.Client, which does nothing when the address is empty.
+func NewClient(config config.StatsDConfig) (Client, error) {
+	if config.Address == "" {
+		return &noOpClient{}, nil
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		return nil, errors.Errorf("unsupported sample rate: %v", config.SampleRate)
+	}
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	return &udpClient{
+		conn:   conn,
+		prefix: config.Prefix,
+		rate:   config.SampleRate,
+		tags:   config.Tags,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
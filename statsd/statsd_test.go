@@ -0,0 +1,127 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsd
+
+import (
+	"github.com/kadaan/consulate/config"
+	"net"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	return conn
+}
+
+func receive(t *testing.T, conn *net.UDPConn) string {
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to receive metric: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func newClient(t *testing.T, conn *net.UDPConn, configure func(c *config.StatsDConfig)) Client {
+	c := config.DefaultStatsDConfig()
+	c.Address = conn.LocalAddr().String()
+	if configure != nil {
+		configure(c)
+	}
+	client, err := NewClient(*c)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestClient(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+	client := newClient(t, conn, nil)
+	defer client.Close()
+
+	var tests = []struct {
+		send     func()
+		expected string
+	}{
+		{func() { client.Count("requests", 1, Tag("code", "200"), Tag("url", "/verify/checks")) }, "consulate.requests:1|c|#code:200,url:/verify/checks"},
+		{func() { client.Timing("request_duration", 1500*time.Microsecond) }, "consulate.request_duration:1.5|ms"},
+		{func() { client.Gauge("checks", 3, Tag("service", "a|b,c")) }, "consulate.checks:3|g|#service:a_b_c"},
+	}
+	for _, test := range tests {
+		test.send()
+		if actual := receive(t, conn); actual != test.expected {
+			t.Errorf("want %s, got %s", test.expected, actual)
+		}
+	}
+}
+
+func TestClientWithoutTags(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+	client := newClient(t, conn, func(c *config.StatsDConfig) {
+		c.Prefix = "test."
+		c.Tags = false
+	})
+	defer client.Close()
+
+	client.Gauge("checks", 2, Tag("service", "web.api"), Tag("status", "passing"))
+	if actual, expected := receive(t, conn), "test.checks.web_api.passing:2|g"; actual != expected {
+		t.Errorf("want %s, got %s", expected, actual)
+	}
+}
+
+func TestClientSampleRate(t *testing.T) {
+	conn := listen(t)
+	defer conn.Close()
+	client := newClient(t, conn, func(c *config.StatsDConfig) {
+		c.SampleRate = 0.5
+	})
+	defer client.Close()
+
+	for i := 0; i < 100; i++ {
+		client.Count("requests", 1)
+	}
+	client.Gauge("checks", 1)
+	for {
+		actual := receive(t, conn)
+		if actual == "consulate.checks:1|g" {
+			break
+		}
+		if expected := "consulate.requests:1|c|@0.5"; actual != expected {
+			t.Errorf("want %s, got %s", expected, actual)
+		}
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	if client, err := NewClient(*config.DefaultStatsDConfig()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := client.(*noOpClient); !ok {
+		t.Errorf("want noOpClient, got %T", client)
+	}
+	c := config.DefaultStatsDConfig()
+	c.Address = "localhost:8125"
+	c.SampleRate = 2
+	if _, err := NewClient(*c); err == nil || err.Error() != "unsupported sample rate: 2" {
+		t.Errorf("want unsupported sample rate error, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright © 2018 Joel Baranick <jbaranick@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var serialNumber int64
+
+// Certificate represents a test certificate and its private key.
+type Certificate struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewCertificateAuthority creates a self-signed test certificate authority.
+func NewCertificateAuthority(t *testing.T, commonName string) *Certificate {
+	template := certificateTemplate(commonName)
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	return newCertificate(t, template, nil)
+}
+
+// Issue creates a test certificate signed by the certificate authority, which is valid for
+// 127.0.0.1 and localhost, and for both server and client authentication.
+func (c *Certificate) Issue(t *testing.T, commonName string) *Certificate {
+	template := certificateTemplate(commonName)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+	template.DNSNames = []string{"localhost"}
+	return newCertificate(t, template, c)
+}
+
+// Write writes the PEM encoded certificate and private key to the specified files.
+func (c *Certificate) Write(t *testing.T, certFile string, keyFile string) {
+	if err := ioutil.WriteFile(certFile, c.CertPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, c.KeyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write private key: %v", err)
+	}
+}
+
+// TLSCertificate gets the certificate for use in a tls.Config.
+func (c *Certificate) TLSCertificate(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.CertPEM, c.KeyPEM)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	return cert
+}
+
+// CertPool gets a pool containing the certificate.
+func (c *Certificate) CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.Cert)
+	return pool
+}
+
+func certificateTemplate(commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber:          big.NewInt(atomic.AddInt64(&serialNumber, 1)),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour).Truncate(time.Second),
+		BasicConstraintsValid: true,
+	}
+}
+
+func newCertificate(t *testing.T, template *x509.Certificate, issuer *Certificate) *Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.Cert, issuer.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return &Certificate{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
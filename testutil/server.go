@@ -16,11 +16,13 @@ package testutil
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/hashicorp/consul/sdk/freeport"
 	consulTestUtil "github.com/hashicorp/consul/sdk/testutil"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/hashicorp/go-cleanhttp"
 	"github.com/kadaan/consulate/checks"
 	"github.com/kadaan/consulate/client"
 	"github.com/kadaan/consulate/config"
@@ -35,8 +37,10 @@ import (
 type TestServer struct {
 	spi.RunningServer
 	httpAddr   string
+	scheme     string
 	httpClient *http.Client
 	svr        spi.RunningServer
+	svrconfig  config.ServerConfig
 	consulSvr  *consulTestUtil.TestServer
 }
 
@@ -55,24 +59,45 @@ type WrappedTestServer struct {
 
 // NewTestServer creates a new test Consulate server.
 func NewTestServer(t *testing.T) (*WrappableTestServer, error) {
+	return NewTestServerWithConfig(t, nil)
+}
+
+// NewTestServerWithConfig creates a new test Consulate server, whose configuration
+// is adjusted by the specified function before it is started.
+func NewTestServerWithConfig(t *testing.T, configure func(c *config.ServerConfig)) (*WrappableTestServer, error) {
 	consulServer := newConsulServer(t)
 	ports := freeport.GetT(t, 1)
 	httpAddr := fmt.Sprintf(":%v", ports[0])
 	svrconfig := config.DefaultServerConfig()
 	svrconfig.ListenAddress = httpAddr
 	svrconfig.ConsulAddress = consulServer.HTTPAddr
+	if configure != nil {
+		configure(svrconfig)
+	}
 	svr, err := server.NewServer(svrconfig).Start()
 	if err != nil {
 		if svr != nil {
 			defer svr.Stop()
 		}
+		defer consulServer.Stop()
 		return nil, err
 	}
+	scheme := "http"
+	httpClient := client.CreateClient(config.DefaultClientConfig())
+	if svrconfig.TLSConfig.CertFile != "" {
+		// The test certificates are self-signed, so they are not verified.
+		scheme = "https"
+		transport := cleanhttp.DefaultPooledTransport()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		httpClient = &http.Client{Transport: transport}
+	}
 	testsvr := &WrappableTestServer{
 		TestServer{
 			httpAddr:   httpAddr,
-			httpClient: client.CreateClient(config.DefaultClientConfig()),
+			scheme:     scheme,
+			httpClient: httpClient,
 			svr:        svr,
+			svrconfig:  *svrconfig,
 			consulSvr:  consulServer,
 		},
 	}
@@ -96,6 +121,18 @@ func (s *TestServer) Stop() {
 	}
 }
 
+// Reload applies the configuration, adjusted by the specified function, to the RunningServer
+// and returns a summary of what changed.
+func (s *TestServer) Reload(t *testing.T, configure func(c *config.ServerConfig)) ([]string, error) {
+	svrconfig := s.svrconfig
+	configure(&svrconfig)
+	changes, err := s.svr.Reload(&svrconfig)
+	if err == nil {
+		s.svrconfig = svrconfig
+	}
+	return changes, err
+}
+
 type failer struct {
 	failed bool
 }
@@ -119,7 +156,7 @@ func newConsulServer(t *testing.T) *consulTestUtil.TestServer {
 func (s *TestServer) waitForAPI(t *testing.T) error {
 	f := &failer{}
 	retry.Run(f, func(r *retry.R) {
-		resp, err := s.httpClient.Get(s.Url(t, "/about"))
+		resp, err := s.httpClient.Get(s.Url(t, "/livez"))
 		if err != nil {
 			r.Error(err)
 		}
@@ -154,7 +191,7 @@ func (s *TestServer) Url(t *testing.T, path string) string {
 	if path == "" {
 		t.Fatal("path is empty")
 	}
-	return fmt.Sprintf("http://127.0.0.1%s%s", s.httpAddr, path)
+	return fmt.Sprintf("%s://127.0.0.1%s%s", s.scheme, s.httpAddr, path)
 }
 
 func (s *TestServer) consulUrl(t *testing.T, path string) string {
@@ -219,6 +256,21 @@ func (s *TestServer) AddCheck(t *testing.T, id string, name string, serviceID st
 	s.put(t, "/v1/agent/check/update/"+id, s.encodePayload(t, result))
 }
 
+// AddHTTPCheck adds an HTTP check of the specified url to the test Consul server.
+func (s *TestServer) AddHTTPCheck(t *testing.T, id string, name string, serviceID string, url string, interval string, timeout string) {
+	chk := map[string]interface{}{
+		"ID":       id,
+		"Name":     name,
+		"HTTP":     url,
+		"Interval": interval,
+		"Timeout":  timeout,
+	}
+	if serviceID != "" {
+		chk["ServiceID"] = serviceID
+	}
+	s.put(t, "/v1/agent/check/register", s.encodePayload(t, chk))
+}
+
 func (s *TestServer) put(t *testing.T, path string, body io.Reader) *http.Response {
 	req, err := http.NewRequest("PUT", s.consulUrl(t, path), body)
 	if err != nil {
@@ -260,6 +312,12 @@ func (w *WrappedTestServer) Stop() {
 	defer w.s.Stop()
 }
 
+// Reload applies the configuration, adjusted by the specified function, to the RunningServer
+// and returns a summary of what changed.
+func (w *WrappedTestServer) Reload(configure func(c *config.ServerConfig)) ([]string, error) {
+	return w.s.Reload(w.t, configure)
+}
+
 // Client returns the http.Client used to communicate with the test Consulate server.
 func (w *WrappedTestServer) Client() *http.Client {
 	return w.s.Client()
@@ -285,6 +343,11 @@ func (w *WrappedTestServer) AddCheck(id string, name string, serviceID string, s
 	w.s.AddCheck(w.t, id, name, serviceID, status, output)
 }
 
+// AddHTTPCheck adds an HTTP check of the specified url to the test Consul server.
+func (w *WrappedTestServer) AddHTTPCheck(id string, name string, serviceID string, url string, interval string, timeout string) {
+	w.s.AddHTTPCheck(w.t, id, name, serviceID, url, interval, timeout)
+}
+
 // GetConsulNodeName returns the test Consul server's node name.
 func (w *WrappedTestServer) GetConsulNodeName() string {
 	return w.s.GetConsulNodeName()